@@ -0,0 +1,364 @@
+package fibvec
+
+import (
+	"container/heap"
+	"math"
+	"math/bits"
+)
+
+// Sum returns the sum of all the values in the
+// vector. This returns false if the sum overflows.
+// The sum is updated as values are added so this
+// usually doesn't need to decode the values.
+func (v *Vector) Sum() (int, bool) {
+	if !v.summed {
+		v.sum, v.sumOK = v.streamSum()
+		v.summed = true
+	}
+
+	return v.sum, v.sumOK
+}
+
+// streamSum computes the sum
+// by decoding all the values.
+func (v *Vector) streamSum() (int, bool) {
+	sum := 0
+	ok := true
+
+	d := v.decoder(0)
+	for i := 0; i < v.length && ok; i++ {
+		n, _ := d.next()
+		sum, ok = addInt(sum, n)
+	}
+
+	return sum, ok
+}
+
+// SumRange returns the sum of the values from start to end-1
+// without allocating a slice for them. Like Sum, this returns
+// false if the sum overflows. This is named SumRange since Sum
+// already returns the sum of all the values. The sum of an empty
+// range is zero.
+func (v *Vector) SumRange(start, end int) (int, bool) {
+	if end < start {
+		panic("fibvec: end must not be less than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > v.length {
+		panic("fibvec: index out of bounds")
+	} else if end == start {
+		return 0, true
+	}
+
+	if start == 0 && end == v.length {
+		return v.Sum()
+	}
+
+	sum := 0
+	ok := true
+
+	d := v.decoder(start)
+	for i := start; i < end && ok; i++ {
+		n, _ := d.next()
+		sum, ok = addInt(sum, n)
+	}
+
+	return sum, ok
+}
+
+// Aggregate folds the values from start to end-1 into a single
+// value. This starts with init and calls fn with the result so
+// far and each value in order. Like SumRange, this decodes the
+// values in a single pass without allocating a slice for them.
+// This returns init if the range is empty.
+func (v *Vector) Aggregate(start, end int, fn func(acc, n int) int, init int) int {
+	if end < start {
+		panic("fibvec: end must not be less than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > v.length {
+		panic("fibvec: index out of bounds")
+	} else if end == start {
+		return init
+	}
+
+	acc := init
+	d := v.decoder(start)
+	for i := start; i < end; i++ {
+		n, _ := d.next()
+		acc = fn(acc, n)
+	}
+
+	return acc
+}
+
+// WindowSum returns the sums of every size consecutive
+// values, ie., the ith element of the result is the sum
+// of the values from i to i+size-1. This returns an empty
+// slice if size is greater than the vector length. This
+// panics if one of the sums overflows.
+func (v *Vector) WindowSum(size int) []int {
+	if size <= 0 {
+		panic("fibvec: window size must be greater than zero")
+	} else if size > v.length {
+		return []int{}
+	}
+
+	// lead decodes the values entering the
+	// window while lag decodes the values
+	// leaving it.
+	lead := v.decoder(0)
+	lag := v.decoder(0)
+
+	sum := 0
+	ok := true
+	for i := 0; i < size && ok; i++ {
+		n, _ := lead.next()
+		sum, ok = addInt(sum, n)
+	}
+
+	sums := make([]int, 1, v.length-size+1)
+	sums[0] = sum
+	for i := size; i < v.length && ok; i++ {
+		in, _ := lead.next()
+		out, _ := lag.next()
+
+		// Try both orders of adding and
+		// subtracting so that the sum only
+		// overflows if the result does.
+		s, sok := addInt(sum, -out)
+		if sok {
+			s, sok = addInt(s, in)
+		}
+		if !sok {
+			s, sok = addInt(sum, in)
+			if sok {
+				s, sok = addInt(s, -out)
+			}
+		}
+
+		sum, ok = s, sok
+		sums = append(sums, sum)
+	}
+
+	if !ok {
+		panic("fibvec: window sum overflows int")
+	}
+
+	return sums
+}
+
+// hllPrecision is the number of bits used to
+// select a HyperLogLog register. This gives a
+// relative standard error of 1.04/sqrt(2^14)
+// which is about 0.81%.
+const hllPrecision = 14
+
+// CountDistinctApprox returns an estimate of the number of
+// distinct values in the vector. This uses HyperLogLog which
+// has a relative standard error of about 0.81%, ie., around 99%
+// of the estimates are within 2.5% of the actual count. This
+// uses a fixed 16KB of memory regardless of the vector length.
+func (v *Vector) CountDistinctApprox() int {
+	const m = 1 << hllPrecision
+
+	registers := make([]uint8, m)
+	for n := range v.Values() {
+		h := mix64(uint64(n))
+		j := h >> (64 - hllPrecision)
+		rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1)) + 1)
+		if rank > registers[j] {
+			registers[j] = rank
+		}
+	}
+
+	zeros := 0
+	sum := 0.0
+	for _, r := range registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Use linear counting for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(float64(m)/float64(zeros))
+	}
+
+	return int(estimate + 0.5)
+}
+
+// mix64 scrambles the bits of n. This is
+// the finalizer of the SplitMix64 generator.
+func mix64(n uint64) uint64 {
+	n ^= n >> 30
+	n *= 0xbf58476d1ce4e5b9
+	n ^= n >> 27
+	n *= 0x94d049bb133111eb
+	n ^= n >> 31
+	return n
+}
+
+// Min returns the smallest value in the
+// vector or false if the vector is empty.
+func (v *Vector) Min() (int, bool) {
+	min := 0
+	v.ForEach(func(i, n int) bool {
+		if i == 0 || n < min {
+			min = n
+		}
+		return true
+	})
+
+	return min, v.length > 0
+}
+
+// Max returns the largest value in the
+// vector or false if the vector is empty.
+func (v *Vector) Max() (int, bool) {
+	max := 0
+	v.ForEach(func(i, n int) bool {
+		if i == 0 || n > max {
+			max = n
+		}
+		return true
+	})
+
+	return max, v.length > 0
+}
+
+// RunningMax returns the running maximum of the values,
+// ie., the ith element of the result is the maximum of
+// the values from 0 to i.
+func (v *Vector) RunningMax() []int {
+	result := make([]int, 0, v.length)
+	for n := range v.Values() {
+		if len(result) > 0 && result[len(result)-1] > n {
+			n = result[len(result)-1]
+		}
+		result = append(result, n)
+	}
+
+	return result
+}
+
+// RunningMin returns the running minimum of the values,
+// ie., the ith element of the result is the minimum of
+// the values from 0 to i.
+func (v *Vector) RunningMin() []int {
+	result := make([]int, 0, v.length)
+	for n := range v.Values() {
+		if len(result) > 0 && result[len(result)-1] < n {
+			n = result[len(result)-1]
+		}
+		result = append(result, n)
+	}
+
+	return result
+}
+
+// Mode returns the most frequent value and the number of
+// times it occurs. If there are several such values, this
+// returns the one which reached that count first. This
+// counts every distinct value using a map, so its memory
+// usage grows with the number of distinct values. Use
+// ModeApprox for vectors with very large cardinality.
+func (v *Vector) Mode() (value int, count int) {
+	counts := map[int]int{}
+	for n := range v.Values() {
+		c := counts[n] + 1
+		counts[n] = c
+		if c > count {
+			value, count = n, c
+		}
+	}
+
+	return value, count
+}
+
+// ModeApprox is like Mode but only keeps track of at most
+// k values using the Space-Saving algorithm. Any value that
+// occurs more than Len()/k times is guaranteed to be tracked,
+// and the returned count may overestimate the actual count
+// by at most Len()/k. This panics if k is less than 1.
+func (v *Vector) ModeApprox(k int) (value int, count int) {
+	if k < 1 {
+		panic("fibvec: k must be greater than zero")
+	}
+
+	// The counters are kept in a min-heap
+	// so that the one with the least count
+	// is found in constant time.
+	h := &counterHeap{index: make(map[int]int, k)}
+	for n := range v.Values() {
+		if i, ok := h.index[n]; ok {
+			h.counters[i].count++
+			heap.Fix(h, i)
+		} else if h.Len() < k {
+			heap.Push(h, counter{n, 1})
+		} else {
+			// Replace the value with the least
+			// count and inherit its count
+			delete(h.index, h.counters[0].value)
+			h.counters[0].value = n
+			h.counters[0].count++
+			h.index[n] = 0
+			heap.Fix(h, 0)
+		}
+	}
+
+	for _, c := range h.counters {
+		if c.count > count || (c.count == count && c.value < value) {
+			value, count = c.value, c.count
+		}
+	}
+
+	return value, count
+}
+
+// counter is a value tracked
+// by ModeApprox and its count.
+type counter struct {
+	value int
+	count int
+}
+
+// counterHeap is a min-heap of counters ordered
+// by count and then by value. index maps each
+// value to the position of its counter.
+type counterHeap struct {
+	counters []counter
+	index    map[int]int
+}
+
+func (h *counterHeap) Len() int {
+	return len(h.counters)
+}
+
+func (h *counterHeap) Less(i, j int) bool {
+	a, b := h.counters[i], h.counters[j]
+	return a.count < b.count || (a.count == b.count && a.value < b.value)
+}
+
+func (h *counterHeap) Swap(i, j int) {
+	h.counters[i], h.counters[j] = h.counters[j], h.counters[i]
+	h.index[h.counters[i].value] = i
+	h.index[h.counters[j].value] = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(counter)
+	h.index[c.value] = len(h.counters)
+	h.counters = append(h.counters, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	c := h.counters[len(h.counters)-1]
+	h.counters = h.counters[:len(h.counters)-1]
+	delete(h.index, c.value)
+	return c
+}
@@ -0,0 +1,397 @@
+package fibvec
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowSum(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e3) - 1e3)
+	}
+
+	values := vec.ToSlice()
+	for _, size := range []int{1, 2, 7, 100, len(values)} {
+		expected := []int{}
+		for i := 0; i+size <= len(values); i++ {
+			sum := 0
+			for _, v := range values[i : i+size] {
+				sum += v
+			}
+			expected = append(expected, sum)
+		}
+
+		if !assert.Equal(t, expected, vec.WindowSum(size)) {
+			break
+		}
+	}
+
+	assert.Empty(t, vec.WindowSum(len(values)+1))
+	assert.Empty(t, NewVector().WindowSum(1))
+}
+
+func TestSum(t *testing.T) {
+	vec := NewVector()
+	sum, ok := vec.Sum()
+	assert.True(t, ok)
+	assert.Equal(t, 0, sum)
+
+	expected := 0
+	for i := 0; i < 1e4; i++ {
+		v := rand.Intn(2e6) - 1e6
+
+		expected += v
+		vec.Add(v)
+	}
+
+	sum, ok = vec.Sum()
+	assert.True(t, ok)
+	assert.Equal(t, expected, sum)
+}
+
+func TestSumRange(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+		vec.Add(values[i])
+	}
+
+	for i := 0; i < 100; i++ {
+		start := rand.Intn(len(values))
+		end := start + 1 + rand.Intn(len(values)-start)
+
+		expected := 0
+		for _, v := range values[start:end] {
+			expected += v
+		}
+
+		sum, ok := vec.SumRange(start, end)
+		assert.True(t, ok)
+		if !assert.Equal(t, expected, sum) {
+			break
+		}
+	}
+
+	esum, eok := vec.Sum()
+	sum, ok := vec.SumRange(0, len(values))
+	assert.Equal(t, eok, ok)
+	assert.Equal(t, esum, sum)
+
+	big := NewVectorFromSlice([]int{1, MaxValue, MaxValue, 1})
+	_, ok = big.SumRange(1, 3)
+	assert.False(t, ok)
+
+	sum, ok = vec.SumRange(3, 8)
+	assert.True(t, ok)
+	assert.Equal(t, values[3]+values[4]+values[5]+values[6]+values[7], sum)
+
+	// Empty ranges sum to zero
+	for _, i := range []int{0, 5, len(values)} {
+		sum, ok = vec.SumRange(i, i)
+		assert.True(t, ok)
+		assert.Equal(t, 0, sum)
+	}
+	sum, ok = NewVector().SumRange(0, 0)
+	assert.True(t, ok)
+	assert.Equal(t, 0, sum)
+
+	assert.Panics(t, func() { vec.SumRange(5, 4) })
+	assert.Panics(t, func() { vec.SumRange(-1, 5) })
+	assert.Panics(t, func() { vec.SumRange(0, len(values)+1) })
+}
+
+func TestAggregate(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+		vec.Add(values[i])
+	}
+
+	larger := func(acc, n int) int {
+		if n > acc {
+			return n
+		}
+		return acc
+	}
+
+	for i := 0; i < 100; i++ {
+		start := rand.Intn(len(values))
+		end := start + 1 + rand.Intn(len(values)-start)
+
+		expected := values[start]
+		for _, v := range values[start:end] {
+			expected = larger(expected, v)
+		}
+
+		result := vec.Aggregate(start, end, larger, math.MinInt)
+		if !assert.Equal(t, expected, result) {
+			break
+		}
+	}
+
+	count := func(acc, n int) int { return acc + 1 }
+	assert.Equal(t, 10, vec.Aggregate(5, 15, count, 0))
+	assert.Equal(t, values[7], vec.Aggregate(7, 8, larger, math.MinInt))
+
+	// Empty ranges return init
+	assert.Equal(t, 42, vec.Aggregate(5, 5, count, 42))
+	assert.Equal(t, 42, vec.Aggregate(len(values), len(values), count, 42))
+	assert.Equal(t, 42, NewVector().Aggregate(0, 0, count, 42))
+	assert.Panics(t, func() { vec.Aggregate(5, 4, count, 0) })
+	assert.Panics(t, func() { vec.Aggregate(0, len(values)+1, count, 0) })
+}
+
+func TestSumCached(t *testing.T) {
+	checkSum := func(vec *Vector) bool {
+		sum, ok := vec.Sum()
+		esum, eok := vec.streamSum()
+		return assert.Equal(t, eok, ok) && assert.Equal(t, esum, sum)
+	}
+
+	vec := NewVectorWithOptions(WithBase(-1e6))
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+		if i%1000 == 0 && !checkSum(vec) {
+			return
+		}
+	}
+	checkSum(vec)
+
+	vec.Extend(buildDeterministic(100, 1))
+	checkSum(vec)
+
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+	nvec := &Vector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	checkSum(nvec)
+
+	// A tampered cached sum is not trusted
+	tvec := vec.Clone()
+	tvec.Sum()
+	tvec.sum++
+	data, err = tvec.GobEncode()
+	assert.Nil(t, err)
+	nvec = &Vector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.False(t, nvec.summed)
+	checkSum(nvec)
+
+	data, err = tvec.MarshalBinary()
+	assert.Nil(t, err)
+	nvec = &Vector{}
+	assert.Nil(t, nvec.UnmarshalBinary(data))
+	assert.False(t, nvec.summed)
+	checkSum(nvec)
+
+	nvec.Add(MaxValue - 1e6)
+	nvec.Add(MaxValue - 1e6)
+	checkSum(nvec)
+
+	code, bits := fibencode(toSignMagnitude(5))
+	nvec = NewVector()
+	nvec.Add(3)
+	nvec.AddRawCode(code, bits)
+	sum, _ := nvec.Sum()
+	assert.Equal(t, 8, sum)
+}
+
+func TestSumOverflow(t *testing.T) {
+	vec := NewVector()
+	vec.Add(MaxValue)
+	vec.Add(MaxValue)
+	vec.Add(MinValue)
+
+	_, ok := vec.Sum()
+	assert.False(t, ok)
+	assert.Panics(t, func() { vec.WindowSum(2) })
+
+	vec = NewVector()
+	vec.Add(MinValue)
+	vec.Add(-10)
+	_, ok = vec.Sum()
+	assert.False(t, ok)
+
+	// The window sums fit even if
+	// the running total doesn't
+	vec = NewVector()
+	values := []int{MaxValue, 3, MinValue, MaxValue, -3}
+	for _, v := range values {
+		vec.Add(v)
+	}
+	assert.Equal(t, []int{3, 3, -3}, vec.WindowSum(3))
+
+	_, ok = addInt(math.MaxInt64, 1)
+	assert.False(t, ok)
+	_, ok = addInt(math.MinInt64, -1)
+	assert.False(t, ok)
+}
+
+func TestMinMax(t *testing.T) {
+	vec := NewVector()
+	_, ok := vec.Min()
+	assert.False(t, ok)
+	_, ok = vec.Max()
+	assert.False(t, ok)
+
+	values := make([]int, 1e4)
+	emin, emax := math.MaxInt, math.MinInt
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+		vec.Add(values[i])
+		if values[i] < emin {
+			emin = values[i]
+		}
+		if values[i] > emax {
+			emax = values[i]
+		}
+	}
+
+	min, ok := vec.Min()
+	assert.True(t, ok)
+	assert.Equal(t, emin, min)
+	max, ok := vec.Max()
+	assert.True(t, ok)
+	assert.Equal(t, emax, max)
+
+	// Extreme and all negative values
+	vec = NewVectorFromSlice([]int{-3, MinValue, -1, -7})
+	min, _ = vec.Min()
+	max, _ = vec.Max()
+	assert.Equal(t, MinValue, min)
+	assert.Equal(t, -1, max)
+
+	vec = NewVectorFromSlice([]int{0, MaxValue, -1})
+	min, _ = vec.Min()
+	max, _ = vec.Max()
+	assert.Equal(t, -1, min)
+	assert.Equal(t, MaxValue, max)
+}
+
+func TestCountDistinctApprox(t *testing.T) {
+	assert.Equal(t, 0, NewVector().CountDistinctApprox())
+
+	for _, count := range []int{100, 1e4, 1e5} {
+		vec := NewVector()
+		for i := 0; i < 2e5; i++ {
+			vec.Add(rand.Intn(count) * 7919)
+		}
+
+		// Make sure that every value is present
+		for i := 0; i < count; i++ {
+			vec.Add(i * 7919)
+		}
+
+		estimate := float64(vec.CountDistinctApprox())
+		assert.InDelta(t, count, estimate, 0.03*float64(count))
+	}
+}
+
+func TestRunningMaxMin(t *testing.T) {
+	assert.Equal(t, []int{}, NewVector().RunningMax())
+	assert.Equal(t, []int{}, NewVector().RunningMin())
+
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	values := vec.ToSlice()
+	maxs := make([]int, len(values))
+	mins := make([]int, len(values))
+	for i, v := range values {
+		maxs[i] = v
+		mins[i] = v
+		for _, w := range values[:i] {
+			if w > maxs[i] {
+				maxs[i] = w
+			}
+			if w < mins[i] {
+				mins[i] = w
+			}
+		}
+	}
+
+	assert.Equal(t, maxs, vec.RunningMax())
+	assert.Equal(t, mins, vec.RunningMin())
+}
+
+func TestMode(t *testing.T) {
+	vec := NewVector()
+	value, count := vec.Mode()
+	assert.Equal(t, 0, value)
+	assert.Equal(t, 0, count)
+
+	value, count = vec.ModeApprox(10)
+	assert.Equal(t, 0, value)
+	assert.Equal(t, 0, count)
+
+	// Every fifth value is the dominant
+	// value while the others are noise
+	const dominant = -42
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1e5; i++ {
+		if i%5 == 0 {
+			vec.Add(dominant)
+		} else {
+			vec.Add(r.Intn(1e6))
+		}
+	}
+
+	value, count = vec.Mode()
+	assert.Equal(t, dominant, value)
+	assert.Equal(t, int(2e4), count)
+
+	value, count = vec.ModeApprox(100)
+	assert.Equal(t, dominant, value)
+	assert.True(t, count >= 2e4)
+	assert.True(t, count <= 2e4+vec.Len()/100)
+
+	assert.Panics(t, func() { vec.ModeApprox(0) })
+
+	// Compare with a Space-Saving implementation
+	// that scans every counter on a miss
+	naive := func(values []int, k int) (value int, count int) {
+		counts := map[int]int{}
+		for _, n := range values {
+			if c, ok := counts[n]; ok {
+				counts[n] = c + 1
+			} else if len(counts) < k {
+				counts[n] = 1
+			} else {
+				minv, minc := 0, math.MaxInt
+				for m, c := range counts {
+					if c < minc || (c == minc && m < minv) {
+						minv, minc = m, c
+					}
+				}
+				delete(counts, minv)
+				counts[n] = minc + 1
+			}
+		}
+		for n, c := range counts {
+			if c > count || (c == count && n < value) {
+				value, count = n, c
+			}
+		}
+		return value, count
+	}
+
+	for _, k := range []int{1, 2, 7, 50} {
+		values := make([]int, 2e3)
+		for i := range values {
+			values[i] = r.Intn(30) * r.Intn(30)
+		}
+		vec := NewVectorFromSlice(values)
+		ev, ec := naive(values, k)
+		value, count = vec.ModeApprox(k)
+		assert.Equal(t, ev, value, "k = %d", k)
+		assert.Equal(t, ec, count, "k = %d", k)
+	}
+}
@@ -0,0 +1,441 @@
+package fibvec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/robskie/bit"
+)
+
+// The binary format starts with binaryMagic and
+// binaryVersion followed by the mode flags, base,
+// length, popcount, number of bits, cached sum, and
+// the sampling block sizes if the flagSampling flag
+// is set. The words of the bit array come next in little
+// endian order, then the rank and select samples as
+// delta encoded varints, the dictionary if the
+// flagDictionary flag is set, and the number of flag
+// bits if the flagElementFlags flag is set. The cached
+// sum is ignored when reading since it can't be checked
+// without decoding the values.
+//
+// binaryVersion is versioned separately from the gob
+// FormatVersion since the two formats change independently.
+// It is bumped on any change to the binary layout that
+// older readers can't skip using the mode flags, and
+// readers reject any version other than the ones they
+// know.
+const (
+	binaryMagic   = "FIBV"
+	binaryVersion = 1
+)
+
+// TranscodeGobToBinary reads a vector encoded with a gob.Encoder
+// from r and writes it to w in the compact binary format. This
+// doesn't stream the input. A gob stream holds the vector in a
+// single message, so the whole vector is decoded into memory
+// before it is written. Only the binary output is written to w
+// as it is produced instead of being buffered.
+func TranscodeGobToBinary(r io.Reader, w io.Writer) error {
+	vec := &Vector{}
+	if err := gob.NewDecoder(r).Decode(vec); err != nil {
+		return err
+	}
+
+	_, err := vec.WriteTo(w)
+	return err
+}
+
+// MarshalBinary encodes the vector into the compact binary
+// format written by WriteTo. This implements
+// encoding.BinaryMarshaler.
+func (v *Vector) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	_, err := v.WriteTo(buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary populates the vector from data encoded by
+// MarshalBinary. This fails if data contains extra bytes after
+// the vector. This implements encoding.BinaryUnmarshaler.
+func (v *Vector) UnmarshalBinary(data []byte) error {
+	vec := &Vector{}
+	n, err := vec.ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		return err
+	} else if n != int64(len(data)) {
+		return fmt.Errorf("fibvec: decode failed (%d trailing bytes)", int64(len(data))-n)
+	}
+
+	*v = *vec
+	return nil
+}
+
+// WriteTo writes the vector to w in the compact binary format
+// and returns the number of bytes written. Unlike GobEncode, the
+// encoded vector is written as it is produced instead of being
+// buffered in memory. This implements io.WriterTo.
+func (v *Vector) WriteTo(w io.Writer) (int64, error) {
+	if !v.initialized {
+		v.init()
+	} else if !v.implicitIndex && !v.indexBuilt() {
+		v.buildIndex()
+	}
+	sum, sumOK := v.Sum()
+
+	ranks, indices := v.ranks, v.indices
+	if v.implicitIndex {
+		ranks, indices = sampleArray{}, sampleArray{}
+	}
+
+	bw := &binaryWriter{w: bufio.NewWriter(w)}
+	bw.write([]byte(binaryMagic))
+	bw.write([]byte{binaryVersion})
+	bw.uvarint(v.flags())
+	bw.varint(int64(v.base))
+	bw.uvarint(uint64(v.length))
+	bw.uvarint(uint64(v.popcount))
+	bw.uvarint(uint64(v.bits.Len()))
+	bw.varint(int64(sum))
+	bw.bool(sumOK)
+	if v.sr != sr || v.ss != ss {
+		bw.uvarint(uint64(v.sr))
+		bw.uvarint(uint64(v.ss))
+	}
+
+	// Missing trailing words are written as zeros
+	words := v.bits.Bits()
+	nwords := (v.bits.Len() + 63) >> 6
+	for i := 0; i < nwords; i++ {
+		bw.word(getBits(words, i<<6, 64))
+	}
+
+	bw.deltas(&ranks)
+	bw.deltas(&indices)
+
+	if v.dict != nil {
+		bw.uvarint(uint64(len(v.dict)))
+		for _, n := range v.dict {
+			bw.varint(int64(n))
+		}
+	}
+	if v.flagBits != 0 {
+		bw.uvarint(uint64(v.flagBits))
+	}
+
+	if bw.err == nil {
+		bw.err = bw.w.Flush()
+	}
+	if bw.err != nil {
+		return bw.n, fmt.Errorf("fibvec: encode failed (%v)", bw.err)
+	}
+
+	return bw.n, nil
+}
+
+// ReadFrom populates the vector from the compact binary format
+// written by WriteTo and returns the number of bytes of the vector
+// that are read. If r doesn't implement io.ByteReader, it is wrapped
+// in a bufio.Reader which may read past the vector. Use a single
+// bufio.Reader to read several vectors or other data after one. The
+// vector is left unchanged if reading fails or if the vector read is
+// inconsistent. Like GobDecode, this doesn't decode the values. This
+// implements io.ReaderFrom.
+func (v *Vector) ReadFrom(r io.Reader) (int64, error) {
+	rr, ok := r.(byteReader)
+	if !ok {
+		rr = bufio.NewReader(r)
+	}
+
+	br := &binaryReader{r: rr}
+	vec := &Vector{}
+	err := vec.readFields(br)
+	if err == nil {
+		err = vec.check()
+	}
+	if err != nil {
+		return br.n, fmt.Errorf("fibvec: decode failed (%w)", err)
+	}
+
+	*v = *vec
+	return br.n, nil
+}
+
+// readFields reads the vector fields from br.
+func (v *Vector) readFields(br *binaryReader) error {
+	magic := make([]byte, len(binaryMagic)+1)
+	br.read(magic)
+	if br.err != nil {
+		return br.err
+	} else if string(magic[:len(binaryMagic)]) != binaryMagic {
+		return errors.New("invalid magic bytes")
+	} else if version := magic[len(binaryMagic)]; version != binaryVersion {
+		return fmt.Errorf("unsupported format version %d", version)
+	}
+
+	flags := br.uvarint()
+	base := br.varint()
+	length := br.uvarint()
+	popcount := br.uvarint()
+	nbits := br.uvarint()
+
+	// The cached sum is skipped like in GobDecode
+	br.varint()
+	br.bool()
+
+	rankBits, selectOnes := uint64(sr), uint64(ss)
+	if flags&flagSampling != 0 {
+		rankBits = br.uvarint()
+		selectOnes = br.uvarint()
+		if br.err == nil && (rankBits > maxBinaryBits || selectOnes > maxBinaryBits ||
+			!validSampling(int(rankBits), int(selectOnes))) {
+			return fmt.Errorf("invalid sampling block sizes %d and %d", rankBits, selectOnes)
+		}
+	}
+
+	if br.err != nil {
+		return br.err
+	} else if flags&^knownFlags != 0 {
+		return fmt.Errorf("unknown mode flags %#x", flags&^knownFlags)
+	} else if nbits < 3 || nbits > maxBinaryBits || length > nbits/3 || popcount != length {
+		return errors.New("invalid lengths")
+	}
+
+	// Don't trust the number of bits
+	// when allocating the bit array
+	capacity := int(nbits)
+	if capacity > maxBinaryAlloc {
+		capacity = maxBinaryAlloc
+	}
+
+	// Read the words in chunks
+	// to reduce the number of reads
+	bits := bit.NewArray(capacity)
+	chunk := make([]byte, 4096)
+	for i := 0; i < int(nbits) && br.err == nil; {
+		nbytes := (int(nbits) - i + 63) >> 6 << 3
+		if nbytes > len(chunk) {
+			nbytes = len(chunk)
+		}
+		br.read(chunk[:nbytes])
+
+		for j := 0; j < nbytes && br.err == nil; j += 8 {
+			size := int(nbits) - i
+			if size > 64 {
+				size = 64
+			}
+			bits.Add(binary.LittleEndian.Uint64(chunk[j:]), size)
+			i += size
+		}
+	}
+
+	ranks := br.deltas(int(nbits/rankBits) + 1)
+	indices := br.deltas(int(length/selectOnes) + 1)
+
+	var dict []int
+	if flags&flagDictionary != 0 {
+		size := br.uvarint()
+		capacity := size
+		if capacity > length {
+			capacity = length
+		}
+
+		dict = make([]int, 0, capacity)
+		for i := uint64(0); i < size && br.err == nil; i++ {
+			dict = append(dict, int(br.varint()))
+		}
+	}
+
+	flagBits := uint64(0)
+	if flags&flagElementFlags != 0 {
+		flagBits = br.uvarint()
+		if br.err == nil && (flagBits == 0 || flagBits > maxFlagBits) {
+			return fmt.Errorf("invalid number of flag bits %d", flagBits)
+		}
+	}
+
+	if br.err != nil {
+		return br.err
+	}
+
+	v.bits = bits
+	v.ranks = ranks
+	v.indices = indices
+	v.popcount = int(popcount)
+	v.length = int(length)
+	v.initialized = true
+	v.compact = flags&flagCompact != 0
+	v.base = int(base)
+	v.implicitLength = flags&flagImplicitLength != 0
+	v.implicitIndex = flags&flagImplicitIndex != 0
+	v.dict = dict
+	v.dictIndex = nil
+	v.flagBits = uint(flagBits)
+	v.sr = int(rankBits)
+	v.ss = int(selectOnes)
+	v.summed = false
+	v.hashed = false
+
+	// The samples are rebuilt
+	// from the bits if omitted
+	if v.ranks.length() == 0 {
+		v.ranks = sampleArray{}
+		v.indices = sampleArray{}
+	}
+
+	return nil
+}
+
+// maxBinaryBits is the largest bit array accepted
+// by ReadFrom and maxBinaryAlloc is the largest
+// one that it allocates before reading the words.
+const (
+	maxBinaryBits  = 1 << 48
+	maxBinaryAlloc = 1 << 26
+)
+
+// binaryWriter writes the fields of the binary
+// format and keeps the first error encountered.
+type binaryWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (bw *binaryWriter) write(p []byte) {
+	if bw.err == nil {
+		var n int
+		n, bw.err = bw.w.Write(p)
+		bw.n += int64(n)
+	}
+}
+
+func (bw *binaryWriter) uvarint(x uint64) {
+	bw.write(bw.buf[:binary.PutUvarint(bw.buf[:], x)])
+}
+
+func (bw *binaryWriter) varint(x int64) {
+	bw.write(bw.buf[:binary.PutVarint(bw.buf[:], x)])
+}
+
+func (bw *binaryWriter) bool(b bool) {
+	if b {
+		bw.write([]byte{1})
+	} else {
+		bw.write([]byte{0})
+	}
+}
+
+func (bw *binaryWriter) word(w uint64) {
+	binary.LittleEndian.PutUint64(bw.buf[:8], w)
+	bw.write(bw.buf[:8])
+}
+
+// deltas writes the number of the non-decreasing
+// samples s followed by their differences.
+func (bw *binaryWriter) deltas(s *sampleArray) {
+	bw.uvarint(uint64(s.length()))
+
+	prev := 0
+	for i := 0; i < s.length(); i++ {
+		n := s.at(i)
+		bw.uvarint(uint64(n - prev))
+		prev = n
+	}
+}
+
+// byteReader is implemented by the
+// readers that binaryReader reads from.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// binaryReader reads the fields of the binary
+// format and keeps the first error encountered.
+type binaryReader struct {
+	r   byteReader
+	n   int64
+	err error
+}
+
+func (br *binaryReader) read(p []byte) {
+	if br.err == nil {
+		var n int
+		n, br.err = io.ReadFull(br.r, p)
+		br.n += int64(n)
+	}
+}
+
+// ReadByte implements io.ByteReader
+// for the varint decoding functions.
+func (br *binaryReader) ReadByte() (byte, error) {
+	if br.err != nil {
+		return 0, br.err
+	}
+
+	b, err := br.r.ReadByte()
+	if err != nil {
+		br.err = err
+		return 0, err
+	}
+
+	br.n++
+	return b, nil
+}
+
+func (br *binaryReader) uvarint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+
+	x, err := binary.ReadUvarint(br)
+	if br.err == nil {
+		br.err = err
+	}
+	return x
+}
+
+func (br *binaryReader) varint() int64 {
+	if br.err != nil {
+		return 0
+	}
+
+	x, err := binary.ReadVarint(br)
+	if br.err == nil {
+		br.err = err
+	}
+	return x
+}
+
+func (br *binaryReader) bool() bool {
+	b, _ := br.ReadByte()
+	return b != 0
+}
+
+// deltas reads samples written by binaryWriter.deltas.
+// This fails if there are more than limit samples.
+func (br *binaryReader) deltas(limit int) sampleArray {
+	size := br.uvarint()
+	if br.err == nil && size > uint64(limit) {
+		br.err = errors.New("too many samples")
+	}
+	if br.err != nil {
+		return sampleArray{}
+	}
+
+	s := makeSamples(0, int(size))
+	prev := 0
+	for i := uint64(0); i < size && br.err == nil; i++ {
+		prev += int(br.uvarint())
+		s.add(prev)
+	}
+
+	return s
+}
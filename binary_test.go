@@ -0,0 +1,287 @@
+package fibvec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscodeGobToBinary(t *testing.T) {
+	frozen := buildDeterministic(1e4, 5)
+	frozen.Freeze()
+
+	vecs := []*Vector{
+		NewVector(),
+		buildDeterministic(1e4, 1),
+		frozen,
+		NewVectorWithOptions(WithBase(-1e6), WithImplicitLength(), WithoutIndex()),
+		NewVectorFromSlice(skewedValues(1e4)).OptimizeByFrequency(),
+	}
+	for i := 0; i < 1e4; i++ {
+		vecs[3].Add(rand.Intn(2e6) - 1e6)
+	}
+
+	for _, vec := range vecs {
+		gobbed := &bytes.Buffer{}
+		assert.Nil(t, gob.NewEncoder(gobbed).Encode(vec))
+		gobSize := gobbed.Len()
+
+		buf := &bytes.Buffer{}
+		assert.Nil(t, TranscodeGobToBinary(gobbed, buf))
+		assert.True(t, buf.Len() < gobSize)
+
+		size := buf.Len()
+		nvec := &Vector{}
+		n, err := nvec.ReadFrom(buf)
+		assert.Nil(t, err)
+		assert.EqualValues(t, size, n)
+
+		assert.Equal(t, vec.Len(), nvec.Len())
+		for i := 0; i < vec.Len(); i++ {
+			if !assert.Equal(t, vec.Get(i), nvec.Get(i)) {
+				break
+			}
+		}
+		assert.Equal(t, vec.ContentHash(), nvec.ContentHash())
+
+		sum, ok := vec.Sum()
+		nsum, nok := nvec.Sum()
+		assert.Equal(t, sum, nsum)
+		assert.Equal(t, ok, nok)
+
+		if vec.Len() > 0 {
+			last := vec.Get(vec.Len() - 1)
+			nvec.Add(last)
+			assert.Equal(t, last, nvec.Get(vec.Len()))
+		}
+	}
+
+	assert.NotNil(t, TranscodeGobToBinary(bytes.NewReader([]byte{1, 2, 3}), &bytes.Buffer{}))
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	a := buildDeterministic(1e5, 1)
+	b := NewVectorWithOptions(WithBase(-10))
+	b.AddBatch([]int{-10, 0, 10})
+
+	buf := &bytes.Buffer{}
+	na, err := a.WriteTo(buf)
+	assert.Nil(t, err)
+	nb, err := b.WriteTo(buf)
+	assert.Nil(t, err)
+	assert.EqualValues(t, buf.Len(), na+nb)
+
+	// Read the vectors one after the other using
+	// short reads through a single bufio.Reader
+	r := bufio.NewReader(iotest.HalfReader(iotest.OneByteReader(buf)))
+
+	va := NewVector()
+	n, err := va.ReadFrom(r)
+	assert.Nil(t, err)
+	assert.Equal(t, na, n)
+	assert.True(t, a.Equal(va))
+	assert.Equal(t, a.ranks, va.ranks)
+	assert.Equal(t, a.indices, va.indices)
+
+	vb := NewVector()
+	n, err = vb.ReadFrom(r)
+	assert.Nil(t, err)
+	assert.Equal(t, nb, n)
+	assert.Equal(t, []int{-10, 0, 10}, vb.ToSlice())
+	vb.Add(-10)
+	assert.Panics(t, func() { vb.Add(-11) })
+
+	_, err = NewVector().ReadFrom(r)
+	assert.NotNil(t, err)
+
+	// A reader that is not an io.ByteReader is buffered
+	buf.Reset()
+	a.WriteTo(buf)
+	counter := &countingReader{r: buf}
+	va = NewVector()
+	n, err = va.ReadFrom(counter)
+	assert.Nil(t, err)
+	assert.Equal(t, na, n)
+	assert.True(t, a.Equal(va))
+	assert.True(t, int64(counter.reads)*1000 < na)
+
+	_, err = a.WriteTo(&failingWriter{10})
+	assert.NotNil(t, err)
+}
+
+// countingReader counts the
+// number of calls to Read.
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+// failingWriter fails after
+// writing n more bytes.
+type failingWriter struct {
+	n int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > f.n {
+		n := f.n
+		f.n = 0
+		return n, errors.New("write failed")
+	}
+
+	f.n -= len(p)
+	return len(p), nil
+}
+
+func TestMarshalBinary(t *testing.T) {
+	vecs := []*Vector{
+		NewVector(),
+		buildDeterministic(1e5, 1),
+		NewVectorFromSlice(skewedValues(1e4)).OptimizeByFrequency(),
+	}
+
+	for _, vec := range vecs {
+		data, err := vec.MarshalBinary()
+		assert.Nil(t, err)
+
+		nvec := &Vector{}
+		assert.Nil(t, nvec.UnmarshalBinary(data))
+		assert.True(t, vec.Equal(nvec))
+		assert.Equal(t, vec.ContentHash(), nvec.ContentHash())
+		assert.Equal(t, vec.ranks, nvec.ranks)
+		assert.Equal(t, vec.indices, nvec.indices)
+
+		// Marshaling again gives the same bytes
+		ndata, err := nvec.MarshalBinary()
+		assert.Nil(t, err)
+		assert.Equal(t, data, ndata)
+
+		gobbed, _ := vec.GobEncode()
+		assert.True(t, len(data) < len(gobbed))
+	}
+
+	data, _ := vecs[1].MarshalBinary()
+	gobbed, _ := vecs[1].GobEncode()
+	t.Logf("binary: %d bytes, gob: %d bytes", len(data), len(gobbed))
+
+	vec := NewVectorFromSlice([]int{1, 2, 3})
+	assert.NotNil(t, vec.UnmarshalBinary(append(data, 0)))
+	assert.NotNil(t, vec.UnmarshalBinary(data[:len(data)-1]))
+	assert.Equal(t, []int{1, 2, 3}, vec.ToSlice())
+}
+
+func TestReadFromInvalid(t *testing.T) {
+	vec := buildDeterministic(1e3, 1)
+	buf := &bytes.Buffer{}
+	_, err := vec.WriteTo(buf)
+	assert.Nil(t, err)
+	data := buf.Bytes()
+
+	// Every truncated input fails
+	for i := 0; i < len(data); i += 7 {
+		_, err := (&Vector{}).ReadFrom(bytes.NewReader(data[:i]))
+		if !assert.NotNil(t, err) {
+			break
+		}
+	}
+
+	bad := append([]byte{}, data...)
+	bad[0] = 'X'
+	_, err = (&Vector{}).ReadFrom(bytes.NewReader(bad))
+	assert.NotNil(t, err)
+
+	// Unknown versions are rejected including
+	// the ones used by the gob format
+	assert.Equal(t, byte(binaryVersion), data[len(binaryMagic)])
+	for _, version := range []byte{0, binaryVersion + 1, FormatVersion, 255} {
+		bad = append([]byte{}, data...)
+		bad[len(binaryMagic)] = version
+		_, err = (&Vector{}).ReadFrom(bytes.NewReader(bad))
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "unsupported format version")
+		}
+	}
+
+	// A failed read leaves the vector as is
+	nvec := NewVectorFromSlice([]int{1, 2, 3})
+	_, err = nvec.ReadFrom(bytes.NewReader(data[:len(data)/2]))
+	assert.NotNil(t, err)
+	assert.Equal(t, []int{1, 2, 3}, nvec.ToSlice())
+}
+
+// TestByteOrder simulates reading the binary form on a
+// host with the other byte order, where the words appear
+// byte-swapped when the little endian bytes are loaded.
+func TestByteOrder(t *testing.T) {
+	vec := buildDeterministic(1e4, 1)
+	values := vec.ToSlice()
+
+	data, err := vec.MarshalBinary()
+	assert.Nil(t, err)
+
+	nwords := (vec.bits.Len() + 63) >> 6
+	words := vec.bits.Bits()[:nwords]
+	wordBytes := make([]byte, nwords*8)
+	for i, w := range words {
+		binary.LittleEndian.PutUint64(wordBytes[i*8:], w)
+	}
+
+	// The words are stored in little endian order
+	offset := bytes.Index(data, wordBytes)
+	assert.True(t, offset > 0)
+
+	// Byte swap the stored words like a big endian
+	// host that writes its words as they are in memory
+	swapped := append([]byte{}, data...)
+	for i := 0; i < nwords; i++ {
+		w := swapped[offset+i*8 : offset+i*8+8]
+		binary.BigEndian.PutUint64(w, binary.LittleEndian.Uint64(w))
+	}
+
+	// Decoding them as little endian words
+	// either fails or yields other values
+	nvec := &Vector{}
+	if nvec.UnmarshalBinary(swapped) == nil {
+		assert.NotEqual(t, values, nvec.ToSlice())
+	}
+
+	// Loading the swapped words as big endian
+	// words restores them, and decoding them
+	// yields the original values
+	loaded := vec.Clone()
+	lwords := loaded.bits.Bits()
+	for i := range lwords[:nwords] {
+		lwords[i] = binary.BigEndian.Uint64(swapped[offset+i*8:])
+	}
+	assert.Equal(t, values, loaded.ToSlice())
+	assert.Equal(t, values[4321:4330], loaded.GetValues(4321, 4330))
+	for i := 0; i < 100; i++ {
+		k := rand.Intn(len(values))
+		if !assert.Equal(t, values[k], loaded.Get(k)) {
+			break
+		}
+	}
+
+	nvec = &Vector{}
+	assert.Nil(t, nvec.UnmarshalBinary(data))
+	assert.Equal(t, values, nvec.ToSlice())
+
+	gobbed, err := vec.GobEncode()
+	assert.Nil(t, err)
+	nvec = &Vector{}
+	assert.Nil(t, nvec.GobDecode(gobbed))
+	assert.Equal(t, values, nvec.ToSlice())
+}
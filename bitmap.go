@@ -0,0 +1,78 @@
+package fibvec
+
+import (
+	"fmt"
+
+	"github.com/robskie/bit"
+)
+
+// Contains returns true if n is in the vector. This decodes
+// the values until n is found. Use RangeQuery for sorted
+// vectors or ToBitmap for repeated lookups.
+func (v *Vector) Contains(n int) bool {
+	d := v.decoder(0)
+	for i := 0; i < v.length; i++ {
+		if m, _ := d.next(); m == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ToBitmap returns a bit array where the nth bit is set if
+// n is in the vector. Checking if a value is in the set then
+// takes constant time. The bitmap has as many bits as the
+// largest value plus one regardless of the number of values,
+// so this is only suitable for dense sets of small values,
+// eg., a million values below 8 million take 1 MB instead of
+// the few hundred KB of the vector. Use ToBitmapLimit if the
+// values are not known to be small. This panics if the vector
+// contains a negative value.
+func (v *Vector) ToBitmap() *bit.Array {
+	var words []uint64
+	nbits := 0
+
+	d := v.decoder(0)
+	for i := 0; i < v.length; i++ {
+		n, _ := d.next()
+		if n < 0 {
+			panic("fibvec: bitmap cannot contain negative values")
+		}
+
+		if n >= nbits {
+			nbits = n + 1
+			for len(words)<<6 < nbits {
+				words = append(words, 0)
+			}
+		}
+		words[n>>6] |= 1 << uint(n&63)
+	}
+
+	bitmap := bit.NewArray(nbits)
+	for i, w := range words {
+		size := nbits - i<<6
+		if size > 64 {
+			size = 64
+		}
+		bitmap.Add(w, size)
+	}
+
+	return bitmap
+}
+
+// ToBitmapLimit is like ToBitmap but returns an error without
+// allocating the bitmap if it would need more than maxBits bits.
+func (v *Vector) ToBitmapLimit(maxBits int) (*bit.Array, error) {
+	d := v.decoder(0)
+	for i := 0; i < v.length; i++ {
+		n, _ := d.next()
+		if n < 0 {
+			panic("fibvec: bitmap cannot contain negative values")
+		} else if n >= maxBits {
+			return nil, fmt.Errorf("fibvec: value %d does not fit in a bitmap of %d bits", n, maxBits)
+		}
+	}
+
+	return v.ToBitmap(), nil
+}
@@ -0,0 +1,77 @@
+package fibvec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToBitmap(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e4))
+	}
+	vec.Add(0)
+
+	bitmap := vec.ToBitmap()
+	maxValue := 0
+	for n := range vec.Values() {
+		if n > maxValue {
+			maxValue = n
+		}
+	}
+	assert.Equal(t, maxValue+1, bitmap.Len())
+
+	set := map[int]bool{}
+	for n := range vec.Values() {
+		set[n] = true
+	}
+	for n := 0; n < bitmap.Len(); n++ {
+		if !assert.Equal(t, set[n], bitmap.Get(n, 1) == 1) {
+			break
+		}
+	}
+
+	for i := 0; i < 500; i++ {
+		n := rand.Intn(bitmap.Len())
+		if !assert.Equal(t, vec.Contains(n), bitmap.Get(n, 1) == 1) {
+			break
+		}
+	}
+
+	assert.False(t, vec.Contains(-1))
+	assert.False(t, vec.Contains(maxValue+1))
+	assert.Equal(t, 0, NewVector().ToBitmap().Len())
+
+	vec.Add(-1)
+	assert.True(t, vec.Contains(-1))
+	assert.Panics(t, func() { vec.ToBitmap() })
+}
+
+func TestToBitmapLimit(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e4))
+	}
+	maxValue, _ := vec.Max()
+
+	// The bitmap must fit in maxBits
+	_, err := vec.ToBitmapLimit(maxValue)
+	assert.NotNil(t, err)
+	bitmap, err := vec.ToBitmapLimit(maxValue + 1)
+	assert.Nil(t, err)
+	assert.Equal(t, vec.ToBitmap(), bitmap)
+
+	bitmap, err = NewVector().ToBitmapLimit(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, bitmap.Len())
+
+	huge := NewVector()
+	huge.Add(MaxValue)
+	_, err = huge.ToBitmapLimit(1 << 30)
+	assert.NotNil(t, err)
+
+	vec.Add(-1)
+	assert.Panics(t, func() { vec.ToBitmapLimit(2e4) })
+}
@@ -0,0 +1,23 @@
+//go:build purego || !(386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64 || wasm)
+
+package fibvec
+
+import "encoding/binary"
+
+// bytesAliased is true if byteSliceFromUint64Slice
+// returns the bytes without copying the words.
+const bytesAliased = false
+
+// byteSliceFromUint64Slice returns the little
+// endian bytes of bits. Unlike the unsafe version,
+// the result is a copy and doesn't alias bits. This
+// is also used on big endian architectures where the
+// bytes of the words are in the wrong order.
+func byteSliceFromUint64Slice(bits []uint64) []byte {
+	bytes := make([]byte, len(bits)*8)
+	for i, w := range bits {
+		binary.LittleEndian.PutUint64(bytes[i*8:], w)
+	}
+
+	return bytes
+}
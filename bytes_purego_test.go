@@ -0,0 +1,45 @@
+//go:build purego || !(386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64 || wasm)
+
+package fibvec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPuregoDecode(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	vec := NewVector()
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = r.Intn(1e6) - 5e5
+		vec.Add(values[i])
+	}
+
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+	assert.Equal(t, values, vec.GetValues(0, len(values)))
+	assert.Equal(t, values[123:4567], vec.GetValues(123, 4567))
+	assert.Equal(t, values, vec.ToSlice())
+
+	// Only the words spanned by the
+	// decoded values are copied
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { vec.Get(5000) }))
+
+	d := vec.decoder(5000)
+	assert.True(t, len(d.input) <= decChunk*8)
+	for i := 5000; i < len(values); i++ {
+		n, _ := d.next()
+		if !assert.Equal(t, values[i], n) {
+			break
+		}
+	}
+	_, ok := d.next()
+	assert.False(t, ok)
+}
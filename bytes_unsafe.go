@@ -0,0 +1,20 @@
+//go:build !purego && (386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64 || wasm)
+
+package fibvec
+
+import "unsafe"
+
+// bytesAliased is true if byteSliceFromUint64Slice
+// returns the bytes without copying the words.
+const bytesAliased = true
+
+// byteSliceFromUint64Slice returns the bytes
+// of bits. The result aliases bits so this is
+// only used on little endian architectures.
+func byteSliceFromUint64Slice(bits []uint64) []byte {
+	if len(bits) == 0 {
+		return nil
+	}
+
+	return unsafe.Slice((*byte)(unsafe.Pointer(&bits[0])), len(bits)*8)
+}
@@ -0,0 +1,69 @@
+package fibvec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+// DebugLogger, if not nil, logs anomalies
+// encountered while decoding such as invalid
+// codes and inconsistent rank or index samples.
+// Logging doesn't change the decoding behavior.
+var DebugLogger *log.Logger
+
+// debugf logs to DebugLogger if it is set. Callers
+// in hot paths should check DebugLogger first to
+// avoid evaluating the arguments.
+func debugf(format string, args ...interface{}) {
+	if DebugLogger != nil {
+		DebugLogger.Printf("fibvec: "+format, args...)
+	}
+}
+
+// vectorDump is the JSON document written by DumpJSON.
+type vectorDump struct {
+	Length            int   `json:"length"`
+	Popcount          int   `json:"popcount"`
+	BitLength         int   `json:"bitLength"`
+	Compact           bool  `json:"compact"`
+	Base              int   `json:"base"`
+	RankBlockBits     int   `json:"rankBlockBits"`
+	SelectBlockValues int   `json:"selectBlockValues"`
+	Ranks             []int `json:"ranks"`
+	Indices           []int `json:"indices"`
+}
+
+// DumpJSON writes a JSON document describing the internal
+// structure of the vector to w. This includes the lengths,
+// the rank and select samples and their sampling parameters
+// but not the values. This is meant for offline analysis and
+// bug reports so the document may change between versions.
+func (v *Vector) DumpJSON(w io.Writer) error {
+	if !v.initialized {
+		v.init()
+	} else if !v.indexBuilt() {
+		v.buildIndex()
+	}
+
+	dump := vectorDump{
+		Length:            v.length,
+		Popcount:          v.popcount,
+		BitLength:         v.bits.Len(),
+		Compact:           v.compact,
+		Base:              v.base,
+		RankBlockBits:     v.sr,
+		SelectBlockValues: v.ss,
+		Ranks:             v.ranks.ints(),
+		Indices:           v.indices.ints(),
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dump); err != nil {
+		return fmt.Errorf("fibvec: dump failed (%v)", err)
+	}
+
+	return nil
+}
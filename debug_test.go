@@ -0,0 +1,75 @@
+package fibvec
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	DebugLogger = log.New(buf, "", 0)
+	defer func() { DebugLogger = nil }()
+
+	vec := buildDeterministic(1e3, 1)
+	vec.ranks.set(1, vec.ranks.at(1)+1)
+
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+
+	nvec := NewVector()
+	assert.NotNil(t, nvec.GobDecode(data))
+	assert.Contains(t, buf.String(), "rank samples")
+
+	// A code that is too long to be valid
+	buf.Reset()
+	input := make([]byte, 2*maxCodeBytes)
+	input[0] = 0x3
+	assert.Empty(t, fibdecode(input, 1))
+	assert.Contains(t, buf.String(), "too long")
+
+	// No logging for valid data
+	buf.Reset()
+	vec = buildDeterministic(1e3, 1)
+	data, _ = vec.GobEncode()
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.Equal(t, vec.ToSlice(), nvec.GetValues(0, nvec.Len()))
+	assert.Empty(t, buf.String())
+}
+
+func TestDumpJSON(t *testing.T) {
+	vec := NewVectorWithOptions(WithBase(-5))
+	for i := 0; i < 1e4; i++ {
+		vec.Add(i%1000 - 5)
+	}
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, vec.DumpJSON(buf))
+
+	var dump map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &dump))
+	assert.EqualValues(t, vec.Len(), dump["length"])
+	assert.EqualValues(t, vec.popcount, dump["popcount"])
+	assert.EqualValues(t, vec.bits.Len(), dump["bitLength"])
+	assert.EqualValues(t, -5, dump["base"])
+	assert.EqualValues(t, false, dump["compact"])
+	assert.EqualValues(t, sr, dump["rankBlockBits"])
+	assert.EqualValues(t, ss, dump["selectBlockValues"])
+
+	var parsed vectorDump
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &parsed))
+	assert.Equal(t, vec.ranks.ints(), parsed.Ranks)
+	assert.Equal(t, vec.indices.ints(), parsed.Indices)
+
+	// The values are not included
+	assert.Len(t, dump, 9)
+
+	buf.Reset()
+	assert.Nil(t, (&Vector{}).DumpJSON(buf))
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &parsed))
+	assert.Equal(t, 0, parsed.Length)
+	assert.Equal(t, []int{0}, parsed.Ranks)
+}
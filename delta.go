@@ -0,0 +1,159 @@
+package fibvec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math/bits"
+)
+
+// sd is the number of values between
+// each absolute value sample of a
+// delta vector.
+const sd = 128
+
+// DeltaVector represents a container for non-decreasing
+// integers. Instead of the values themselves, this stores
+// the differences of successive values which are usually
+// much smaller. The value at every sd-th index is sampled
+// so that Get doesn't have to sum all the preceding values.
+type DeltaVector struct {
+	deltas *Vector
+
+	// samples[i] is the
+	// value at index i*sd
+	samples []int
+
+	last int
+}
+
+// NewDeltaVector creates a new delta vector.
+func NewDeltaVector() *DeltaVector {
+	return &DeltaVector{deltas: NewVector()}
+}
+
+// Add adds an integer to the vector. This panics
+// if n is less than the previously added value.
+func (v *DeltaVector) Add(n int) {
+	if v.deltas == nil {
+		v.deltas = NewVector()
+	}
+
+	length := v.deltas.Len()
+	if length > 0 && n < v.last {
+		panic("fibvec: input is less than the last value")
+	}
+
+	if length%sd == 0 {
+		v.samples = append(v.samples, n)
+	}
+
+	if length == 0 {
+		v.deltas.Add(n)
+	} else {
+		v.deltas.Add(n - v.last)
+	}
+	v.last = n
+}
+
+// Get returns the value at index i.
+func (v *DeltaVector) Get(i int) int {
+	if i >= v.Len() {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	j := i / sd
+	sum := v.samples[j]
+	if i == j*sd {
+		return sum
+	}
+
+	d := v.deltas.decoder(j*sd + 1)
+	for k := j * sd; k < i; k++ {
+		n, _ := d.next()
+		sum += n
+	}
+
+	return sum
+}
+
+// PrefixSum returns the sum of the first i stored differences
+// where the first value is its difference from zero. This is the
+// value at index i-1, or zero if i is zero, so it also uses the
+// absolute value samples instead of summing from the start.
+func (v *DeltaVector) PrefixSum(i int) int {
+	if i > v.Len() {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	if i == 0 {
+		return 0
+	}
+	return v.Get(i - 1)
+}
+
+// Len returns the number of values stored.
+func (v *DeltaVector) Len() int {
+	if v.deltas == nil {
+		return 0
+	}
+	return v.deltas.Len()
+}
+
+// Size returns the vector size in bytes.
+func (v *DeltaVector) Size() int {
+	sizeofInt := bits.UintSize / 8
+
+	size := len(v.samples) * sizeofInt
+	if v.deltas != nil {
+		size += v.deltas.Size()
+	}
+
+	return size
+}
+
+// GobEncode encodes this vector into gob streams.
+func (v *DeltaVector) GobEncode() ([]byte, error) {
+	if v.deltas == nil {
+		v.deltas = NewVector()
+	}
+
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+
+	err := checkErr(
+		enc.Encode(v.deltas),
+		enc.Encode(v.samples),
+		enc.Encode(v.last),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: encode failed (%v)", err)
+	}
+
+	return buf.Bytes(), err
+}
+
+// GobDecode populates this vector from gob streams.
+func (v *DeltaVector) GobDecode(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+
+	v.deltas = NewVector()
+	v.samples = nil
+	err := checkErr(
+		dec.Decode(v.deltas),
+		dec.Decode(&v.samples),
+		dec.Decode(&v.last),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: decode failed (%v)", err)
+	}
+
+	return err
+}
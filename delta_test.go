@@ -0,0 +1,148 @@
+package fibvec
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeltaVectorAddGet(t *testing.T) {
+	vec := NewDeltaVector()
+	values := make([]int, 1e5)
+
+	sum := -int(1e6)
+	for i := range values {
+		sum += rand.Intn(1e3)
+
+		values[i] = sum
+		vec.Add(sum)
+	}
+
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+
+	assert.Panics(t, func() { vec.Add(sum - 1) })
+	assert.Equal(t, len(values), vec.Len())
+}
+
+func TestDeltaVectorEncodeDecode(t *testing.T) {
+	vec := NewDeltaVector()
+	values := make([]int, 1e4)
+
+	sum := 0
+	for i := range values {
+		sum += rand.Intn(1e3)
+
+		values[i] = sum
+		vec.Add(sum)
+	}
+
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+
+	nvec := NewDeltaVector()
+	assert.Nil(t, nvec.GobDecode(data))
+	for i, v := range values {
+		if !assert.Equal(t, v, nvec.Get(i)) {
+			break
+		}
+	}
+}
+
+func TestDeltaVectorPrefixSum(t *testing.T) {
+	vec := NewDeltaVector()
+	assert.Equal(t, 0, vec.PrefixSum(0))
+	assert.Panics(t, func() { vec.PrefixSum(1) })
+
+	sum := -int(1e4)
+	for i := 0; i < 1e4; i++ {
+		sum += rand.Intn(1e3)
+		vec.Add(sum)
+	}
+
+	i := 0
+	sum = 0
+	for n := range vec.deltas.Values() {
+		if !assert.Equal(t, sum, vec.PrefixSum(i)) {
+			break
+		}
+		sum += n
+		i++
+	}
+	assert.Equal(t, sum, vec.PrefixSum(vec.Len()))
+	assert.Panics(t, func() { vec.PrefixSum(-1) })
+	assert.Panics(t, func() { vec.PrefixSum(vec.Len() + 1) })
+}
+
+func TestDeltaVectorCompression(t *testing.T) {
+	values := make([]int, 1e5)
+	for i := range values {
+		values[i] = int(rand.Uint32())
+	}
+	sort.Ints(values)
+
+	plain := NewVector()
+	delta := NewDeltaVector()
+	for _, v := range values {
+		plain.Add(v)
+		delta.Add(v)
+	}
+
+	// The gaps between 1e5 sorted uint32s
+	// average around 43000 which take
+	// about 3 bytes instead of 6
+	assert.True(t, delta.Size() < plain.Size()*2/3)
+	fmt.Printf("=== DELTA: %d bytes, PLAIN: %d bytes\n", delta.Size(), plain.Size())
+}
+
+func BenchmarkDeltaVectorGet(b *testing.B) {
+	vec := NewDeltaVector()
+	sum := 0
+	for i := 0; i < 1e5; i++ {
+		sum += rand.Intn(1e3)
+		vec.Add(sum)
+	}
+
+	idx := make([]int, b.N)
+	for i := range idx {
+		idx[i] = rand.Intn(vec.Len())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec.Get(idx[i])
+	}
+}
+
+// BenchmarkDeltaVectorGetPrefixSum gets values by
+// summing all the preceding differences.
+func BenchmarkDeltaVectorGetPrefixSum(b *testing.B) {
+	vec := NewVector()
+	prev := 0
+	for i := 0; i < 1e5; i++ {
+		n := prev + rand.Intn(1e3)
+		vec.Add(n - prev)
+		prev = n
+	}
+
+	idx := make([]int, b.N)
+	for i := range idx {
+		idx[i] = rand.Intn(vec.Len())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sum := 0
+		d := vec.decoder(0)
+		for j := 0; j <= idx[i]; j++ {
+			n, _ := d.next()
+			sum += n
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package fibvec
+
+import "sort"
+
+// OptimizeByFrequency returns a vector with the same values as v
+// but which stores the index of each value in a dictionary of the
+// distinct values instead of the value itself. The dictionary is
+// sorted by descending frequency so that the most common values
+// get the shortest codes which makes them smaller and faster to
+// decode. This is useful for vectors with few distinct values.
+// The dictionary is included in the gob stream and values that
+// are not in it cannot be added to the returned vector. The flags
+// set using AddWithFlag are kept.
+func (v *Vector) OptimizeByFrequency() *Vector {
+	values, flags := v.valuesAndFlags()
+
+	counts := map[int]int{}
+	for _, n := range values {
+		counts[n]++
+	}
+
+	dict := make([]int, 0, len(counts))
+	for n := range counts {
+		dict = append(dict, n)
+	}
+	sort.Slice(dict, func(i, j int) bool {
+		ci, cj := counts[dict[i]], counts[dict[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return dict[i] < dict[j]
+	})
+
+	vec := &Vector{
+		compact:        v.compact,
+		implicitLength: v.implicitLength,
+		implicitIndex:  v.implicitIndex,
+		dict:           dict,
+		flagBits:       v.flagBits,
+		sr:             v.sr,
+		ss:             v.ss,
+	}
+	vec.init()
+	if flags == nil {
+		vec.AddBatch(values)
+	} else {
+		for i, n := range values {
+			vec.add(n, flags[i])
+		}
+	}
+
+	return vec
+}
+
+// valuesAndFlags returns the values of the vector and
+// their flags. The flags are nil if WithFlags is not used.
+func (v *Vector) valuesAndFlags() (values, flags []int) {
+	if v.flagBits == 0 {
+		return v.ToSlice(), nil
+	}
+
+	values = make([]int, v.length)
+	flags = make([]int, v.length)
+
+	// Decode the encoded values
+	// and split off their flags
+	d := v.decoder(0)
+	d.base, d.dict, d.flagBits = 0, nil, 0
+	for i := range values {
+		e, _ := d.next()
+		values[i] = v.value(e >> v.flagBits)
+		flags[i] = e & (1<<v.flagBits - 1)
+	}
+
+	return values, flags
+}
+
+// inDict returns true if n
+// is in the dictionary.
+func (v *Vector) inDict(n int) bool {
+	if v.dictIndex == nil {
+		v.dictIndex = make(map[int]int, len(v.dict))
+		for i, d := range v.dict {
+			v.dictIndex[d] = i
+		}
+	}
+
+	_, ok := v.dictIndex[n]
+	return ok
+}
+
+// offset returns the value that is encoded in
+// place of n, excluding the flag of the value.
+func (v *Vector) offset(n int) int {
+	if v.dict != nil {
+		return v.dictIndex[n] << v.flagBits
+	}
+	return (n - v.base) << v.flagBits
+}
+
+// value reverses offset given the
+// encoded value without its flag.
+func (v *Vector) value(k int) int {
+	if v.dict == nil {
+		return k + v.base
+	} else if k < 0 || k >= len(v.dict) {
+		panic("fibvec: invalid code")
+	}
+
+	return v.dict[k]
+}
@@ -0,0 +1,115 @@
+package fibvec
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// skewedValues returns n values drawn from a few large
+// distinct values where smaller ranks are more frequent.
+func skewedValues(n int) []int {
+	r := rand.New(rand.NewSource(1))
+
+	distinct := make([]int, 20)
+	for i := range distinct {
+		distinct[i] = int(r.Int63n(1e12)) - 5e11
+	}
+
+	values := make([]int, n)
+	for i := range values {
+		k := 0
+		for k < len(distinct)-1 && r.Intn(2) == 0 {
+			k++
+		}
+		values[i] = distinct[k]
+	}
+
+	return values
+}
+
+func TestOptimizeByFrequency(t *testing.T) {
+	values := skewedValues(1e5)
+	vec := NewVectorFromSlice(values)
+
+	ovec := vec.OptimizeByFrequency()
+	assert.Equal(t, len(values), ovec.Len())
+	for i, n := range values {
+		if !assert.Equal(t, n, ovec.Get(i)) {
+			break
+		}
+	}
+	assert.Equal(t, values, ovec.ToSlice())
+	assert.Equal(t, values[100:200], ovec.GetValues(100, 200))
+
+	sum, ok := vec.Sum()
+	osum, ook := ovec.Sum()
+	assert.Equal(t, sum, osum)
+	assert.Equal(t, ok, ook)
+
+	// The most frequent value gets the
+	// shortest code. Compact vectors are
+	// used to exclude the padding bits.
+	mode, _ := vec.Mode()
+	assert.Equal(t, mode, ovec.dict[0])
+	cvec := NewVectorFromSlice(values)
+	cvec.Freeze()
+	cvec = cvec.OptimizeByFrequency()
+	for i, n := range values[:100] {
+		if n == mode {
+			assert.Equal(t, 3, cvec.BitOffset(i+1)-cvec.BitOffset(i))
+		}
+	}
+
+	assert.True(t, ovec.Size() < vec.Size())
+	size := float64(vec.Size())
+	osize := float64(ovec.Size())
+	percentage := ((size - osize) / size) * 100
+	fmt.Printf("=== FREQUENCY COMPRESSION: %.2f%%\n", percentage)
+
+	// Only values in the dictionary can be added
+	ovec.Add(values[5])
+	ovec.Set(0, values[7])
+	assert.Equal(t, values[5], ovec.Get(len(values)))
+	assert.Equal(t, values[7], ovec.Get(0))
+	assert.Panics(t, func() { ovec.Add(1e13) })
+	assert.Panics(t, func() { ovec.Set(1, 1e13) })
+	assert.Panics(t, func() { ovec.AddBatch([]int{values[0], 1e13}) })
+	assert.Equal(t, len(values)+1, ovec.Len())
+
+	// Copying values between vectors with
+	// different dictionaries decodes them
+	other := NewVector()
+	other.Add(-1)
+	other.Extend(ovec)
+	assert.Equal(t, append([]int{-1}, ovec.ToSlice()...), other.ToSlice())
+
+	assert.Equal(t, 0, NewVector().OptimizeByFrequency().Len())
+}
+
+func TestOptimizeByFrequencyEncodeDecode(t *testing.T) {
+	values := skewedValues(1e4)
+	ovec := NewVectorFromSlice(values).OptimizeByFrequency()
+
+	data, err := ovec.GobEncode()
+	assert.Nil(t, err)
+
+	nvec := NewVector()
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.Equal(t, values, nvec.ToSlice())
+	assert.Equal(t, ovec.Get(123), nvec.Get(123))
+	assert.Equal(t, ovec.ContentHash(), nvec.ContentHash())
+
+	nvec.Add(values[3])
+	assert.Equal(t, values[3], nvec.Get(len(values)))
+	assert.Panics(t, func() { nvec.Add(1e13) })
+
+	// Decoding a vector without a
+	// dictionary clears the old one
+	data, err = NewVectorFromSlice([]int{1e13}).GobEncode()
+	assert.Nil(t, err)
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.Equal(t, []int{1e13}, nvec.ToSlice())
+}
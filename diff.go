@@ -0,0 +1,205 @@
+package fibvec
+
+import "github.com/robskie/bit"
+
+// Change describes a single modification to a vector.
+type Change struct {
+	// Index is the index of the modified
+	// value. If Index is equal to the length
+	// of the vector, Value is appended to it.
+	Index int
+
+	// Value is the new value at Index.
+	// This is ignored if Remove is true.
+	Value int
+
+	// Remove is true if the values from
+	// Index onwards are removed.
+	Remove bool
+}
+
+// Diff returns the changes needed to transform v into other.
+// The changes are sorted by index, with replacements coming
+// first followed by either appends or a removal.
+func (v *Vector) Diff(other *Vector) []Change {
+	var a, b []int
+	if v.length > 0 {
+		a = v.GetValues(0, v.length)
+	}
+	if other.length > 0 {
+		b = other.GetValues(0, other.length)
+	}
+
+	changes := []Change{}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			changes = append(changes, Change{Index: i, Value: b[i]})
+		}
+	}
+
+	if len(a) > len(b) {
+		changes = append(changes, Change{Index: len(b), Remove: true})
+	}
+
+	for i := len(a); i < len(b); i++ {
+		changes = append(changes, Change{Index: i, Value: b[i]})
+	}
+
+	return changes
+}
+
+// ApplyChanges returns a new vector which is the result of
+// applying the changes to v. The result has the same sampling
+// options as v but no base, dictionary, or flags since the new
+// values may not fit them.
+func (v *Vector) ApplyChanges(changes []Change) *Vector {
+	var values []int
+	if v.length > 0 {
+		values = v.GetValues(0, v.length)
+	}
+
+	end := -1
+	for _, c := range changes {
+		if c.Index < 0 || c.Index > len(values) {
+			panic("fibvec: invalid change index")
+		}
+
+		switch {
+		case c.Remove:
+			if end < 0 || c.Index < end {
+				end = c.Index
+			}
+		case c.Index == len(values):
+			values = append(values, c.Value)
+		default:
+			values[c.Index] = c.Value
+		}
+	}
+
+	if end >= 0 {
+		values = values[:end]
+	}
+
+	vec := newPlainVectorLike(v)
+	for _, n := range values {
+		vec.Add(n)
+	}
+
+	return vec
+}
+
+// RangeEqual returns true if the length values starting
+// from aStart are equal to the ones starting from bStart.
+// This panics if one of the ranges is out of bounds.
+func (v *Vector) RangeEqual(aStart, bStart, length int) bool {
+	if aStart < 0 || bStart < 0 || length < 0 {
+		panic("fibvec: invalid index")
+	} else if aStart+length > v.length || bStart+length > v.length {
+		panic("fibvec: index out of bounds")
+	} else if length == 0 || aStart == bStart {
+		return true
+	}
+
+	da := v.decoder(aStart)
+	db := v.decoder(bStart)
+	for i := 0; i < length; i++ {
+		a, _ := da.next()
+		b, _ := db.next()
+		if a != b {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Equal returns true if v and other contain the same values
+// in the same order. Vectors with the same base, padding, and
+// no dictionary or flags usually have identical bit arrays if
+// their values are the same so their bits are compared first.
+// The values are decoded and compared if the bits differ.
+func (v *Vector) Equal(other *Vector) bool {
+	if v.length != other.length {
+		return false
+	} else if v == other || v.length == 0 {
+		return true
+	}
+
+	if v.base == other.base && v.compact == other.compact &&
+		v.dict == nil && other.dict == nil &&
+		v.flagBits == 0 && other.flagBits == 0 &&
+		equalBits(v.bits, other.bits) {
+		return true
+	}
+
+	da := v.decoder(0)
+	db := other.decoder(0)
+	for i := 0; i < v.length; i++ {
+		a, _ := da.next()
+		b, _ := db.next()
+		if a != b {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equalBits returns true if
+// a and b have the same bits.
+func equalBits(a, b *bit.Array) bool {
+	n := a.Len()
+	if n != b.Len() {
+		return false
+	}
+
+	wa, wb := a.Bits(), b.Bits()
+	for i := 0; i < n; i += 64 {
+		size := n - i
+		if size > 64 {
+			size = 64
+		}
+		if getBits(wa, i, size) != getBits(wb, i, size) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Jaccard returns the Jaccard similarity of a and b, ie., the
+// size of their intersection divided by the size of their union.
+// Both vectors must be sorted in ascending order and must not
+// contain duplicates. The values are compared in a single pass
+// without building either set. This returns 1 if both are empty.
+func Jaccard(a, b *Vector) float64 {
+	if a.length == 0 && b.length == 0 {
+		return 1
+	}
+
+	da := a.decoder(0)
+	db := b.decoder(0)
+	x, _ := da.next()
+	y, _ := db.next()
+
+	i, j, common := 0, 0, 0
+	for i < a.length && j < b.length {
+		switch {
+		case x < y:
+			x, _ = da.next()
+			i++
+		case x > y:
+			y, _ = db.next()
+			j++
+		default:
+			common++
+			x, _ = da.next()
+			y, _ = db.next()
+			i++
+			j++
+		}
+	}
+
+	union := a.length + b.length - common
+	return float64(common) / float64(union)
+}
@@ -0,0 +1,176 @@
+package fibvec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffApplyChanges(t *testing.T) {
+	sizes := [][2]int{{1e3, 1e3}, {1e3, 1200}, {1e3, 800}, {0, 100}, {100, 0}}
+	for _, size := range sizes {
+		a := NewVector()
+		b := NewVector()
+		for i := 0; i < size[0]; i++ {
+			a.Add(rand.Intn(100))
+		}
+		for i := 0; i < size[1]; i++ {
+			b.Add(rand.Intn(100))
+		}
+
+		c := a.ApplyChanges(a.Diff(b))
+		if !assert.Equal(t, b.Len(), c.Len()) {
+			break
+		}
+		for i := 0; i < b.Len(); i++ {
+			if !assert.Equal(t, b.Get(i), c.Get(i)) {
+				break
+			}
+		}
+	}
+
+	// The changed values may not fit
+	// the base or dictionary of a
+	a := NewVectorWithOptions(WithBase(100))
+	a.AddBatch([]int{100, 150, 200})
+	b := NewVectorWithOptions(WithBase(-10))
+	b.AddBatch([]int{100, -5, 200, 0})
+	assert.Equal(t, b.ToSlice(), a.ApplyChanges(a.Diff(b)).ToSlice())
+	assert.Equal(t, a.ToSlice(), b.ApplyChanges(b.Diff(a)).ToSlice())
+
+	da := NewVectorFromSlice([]int{1, 2, 2, 3}).OptimizeByFrequency()
+	db := NewVectorFromSlice([]int{7, 2, 9}).OptimizeByFrequency()
+	assert.Equal(t, db.ToSlice(), da.ApplyChanges(da.Diff(db)).ToSlice())
+	assert.Equal(t, da.ToSlice(), db.ApplyChanges(db.Diff(da)).ToSlice())
+}
+
+func TestRangeEqual(t *testing.T) {
+	block := make([]int, 1e3)
+	for i := range block {
+		block[i] = rand.Intn(2e3) - 1e3
+	}
+
+	// Two copies of the same
+	// block separated by noise
+	vec := NewVector()
+	for _, v := range block {
+		vec.Add(v)
+	}
+	for i := 0; i < 123; i++ {
+		vec.Add(rand.Intn(10))
+	}
+	for _, v := range block {
+		vec.Add(v)
+	}
+
+	b := len(block) + 123
+	assert.True(t, vec.RangeEqual(0, b, len(block)))
+	assert.True(t, vec.RangeEqual(10, b+10, 500))
+	assert.True(t, vec.RangeEqual(5, 5, 10))
+	assert.True(t, vec.RangeEqual(0, 1, 0))
+	assert.False(t, vec.RangeEqual(0, b+1, len(block)-1))
+	assert.False(t, vec.RangeEqual(1, b, len(block)-1))
+
+	assert.Panics(t, func() { vec.RangeEqual(0, b+1, len(block)) })
+	assert.Panics(t, func() { vec.RangeEqual(-1, 0, 1) })
+}
+
+func TestEqual(t *testing.T) {
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+	}
+
+	a := NewVector()
+	for _, n := range values {
+		a.Add(n)
+	}
+	b := NewVector()
+	b.AddBatch(values)
+
+	assert.True(t, a.Equal(b))
+	assert.True(t, b.Equal(a))
+	assert.True(t, a.Equal(a))
+	assert.True(t, NewVector().Equal(&Vector{}))
+
+	// Different layouts of the same values
+	c := NewVectorWithOptions(WithBase(-1e6))
+	c.AddBatch(values)
+	d := NewVectorFromSlice(values)
+	d.Freeze()
+	assert.True(t, a.Equal(c))
+	assert.True(t, a.Equal(d))
+	assert.True(t, c.Equal(d))
+
+	for _, i := range []int{0, 5000, len(values) - 1} {
+		e := NewVectorFromSlice(values)
+		e.Set(i, values[i]+1)
+		assert.False(t, a.Equal(e))
+		assert.False(t, c.Equal(e))
+		assert.False(t, e.Equal(d))
+	}
+
+	assert.False(t, a.Equal(NewVectorFromSlice(values[1:])))
+	assert.False(t, a.Equal(NewVector()))
+
+	// Dictionaries and flags
+	f := NewVectorWithOptions(WithFlags(2))
+	for i, n := range values {
+		f.AddWithFlag(n, i&3)
+	}
+	o := a.OptimizeByFrequency()
+	assert.True(t, a.Equal(f))
+	assert.True(t, f.Equal(o))
+	assert.True(t, o.Equal(d))
+	assert.True(t, f.OptimizeByFrequency().Equal(c))
+
+	// Vectors with the same options
+	// but with different bit arrays
+	g := NewVectorFromSlice(values)
+	g.Freeze()
+	g.compact = false
+	assert.False(t, equalBits(a.bits, g.bits))
+	assert.True(t, a.Equal(g))
+	assert.True(t, g.Equal(a))
+	g.Set(0, values[0]+1)
+	assert.False(t, a.Equal(g))
+}
+
+func TestJaccard(t *testing.T) {
+	a := NewVectorFromSlice([]int{-5, 1, 2, 3, 10})
+	b := NewVectorFromSlice([]int{1, 3, 4, 10, 20, 30})
+	assert.InDelta(t, 3.0/8, Jaccard(a, b), 1e-12)
+	assert.InDelta(t, 3.0/8, Jaccard(b, a), 1e-12)
+
+	assert.Equal(t, 1.0, Jaccard(a, a))
+	assert.Equal(t, 1.0, Jaccard(a, NewVectorFromSlice(a.ToSlice())))
+	assert.Equal(t, 0.0, Jaccard(a, NewVectorFromSlice([]int{-4, 0, 4, 11})))
+	assert.Equal(t, 0.0, Jaccard(a, NewVector()))
+	assert.Equal(t, 1.0, Jaccard(NewVector(), NewVector()))
+
+	// Compare with maps
+	set := map[int]bool{}
+	for i := 0; i < 1e4; i++ {
+		set[rand.Intn(2e4)] = true
+	}
+	values := []int{}
+	other := []int{}
+	for n := 0; n < 2e4; n++ {
+		if set[n] {
+			values = append(values, n)
+		}
+		if n%3 == 0 {
+			other = append(other, n)
+		}
+	}
+
+	common := 0
+	for _, n := range other {
+		if set[n] {
+			common++
+		}
+	}
+	expected := float64(common) / float64(len(values)+len(other)-common)
+	assert.InDelta(t, expected, Jaccard(NewVectorFromSlice(values), NewVectorFromSlice(other)), 1e-12)
+}
@@ -0,0 +1,62 @@
+package fibvec
+
+// maxFlagBits is the maximum
+// number of flag bits per value.
+const maxFlagBits = 8
+
+// WithFlags reserves the given number of bits in each value
+// for a small flag that can be set using AddWithFlag. The flag
+// is stored in the low bits of the encoded value so the codes
+// stay valid fibonacci codes, ie., no extra runs of 1s are
+// introduced. This reduces the range of values by a factor of
+// 2^bits. Values that are copied by decoding them, eg., when
+// extending a vector with a different number of flag bits,
+// lose their flags.
+func WithFlags(bits int) Option {
+	if bits < 1 || bits > maxFlagBits {
+		panic("fibvec: number of flag bits must be from 1 to 8")
+	}
+
+	return func(v *Vector) {
+		v.flagBits = uint(bits)
+	}
+}
+
+// AddWithFlag adds an integer to the vector together with its
+// flag. Add is the same as AddWithFlag with a zero flag. This
+// panics if flag doesn't fit in the bits reserved by WithFlags.
+func (v *Vector) AddWithFlag(n, flag int) {
+	if flag < 0 || flag >= 1<<v.flagBits {
+		panic("fibvec: flag is out of range")
+	}
+
+	v.add(n, flag)
+}
+
+// GetWithFlag returns the value at index i and its flag.
+// The flag is always zero if WithFlags is not used.
+func (v *Vector) GetWithFlag(i int) (n, flag int) {
+	e := v.getEncoded(i)
+	return v.value(e >> v.flagBits), e & (1<<v.flagBits - 1)
+}
+
+// fitsFlags returns true if n still fits in
+// the range of encodable values when it is
+// shifted to make room for its flag.
+func (v *Vector) fitsFlags(n int) bool {
+	if v.dict != nil {
+		return true
+	}
+
+	limit := flagLimit(v.flagBits)
+	m := n - v.base
+	return m <= limit && m >= -limit
+}
+
+// flagLimit returns the largest magnitude of
+// a value that has the given number of flag
+// bits, ie., shifting it and adding any flag
+// doesn't go beyond MaxValue.
+func flagLimit(bits uint) int {
+	return (MaxValue - (1<<bits - 1)) >> bits
+}
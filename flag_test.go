@@ -0,0 +1,147 @@
+package fibvec
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddGetWithFlag(t *testing.T) {
+	for _, bits := range []int{1, 2, 8} {
+		vec := NewVectorWithOptions(WithFlags(bits))
+
+		limit := flagLimit(uint(bits))
+		values := []int{0, -1, 1, limit, -limit}
+		for i := 0; i < 1e4; i++ {
+			values = append(values, rand.Intn(2e6)-1e6)
+		}
+
+		flags := make([]int, len(values))
+		for i, n := range values {
+			flags[i] = rand.Intn(1 << uint(bits))
+			if i < 5 {
+				flags[i] = 1<<uint(bits) - 1
+			}
+			vec.AddWithFlag(n, flags[i])
+		}
+
+		for i, n := range values {
+			v, f := vec.GetWithFlag(i)
+			if !assert.Equal(t, n, v) || !assert.Equal(t, flags[i], f) {
+				break
+			}
+			if !assert.Equal(t, n, vec.Get(i)) {
+				break
+			}
+		}
+		assert.Equal(t, values, vec.ToSlice())
+
+		// Set keeps the flag
+		vec.Set(3, 5)
+		n, f := vec.GetWithFlag(3)
+		assert.Equal(t, 5, n)
+		assert.Equal(t, flags[3], f)
+
+		assert.Panics(t, func() { vec.AddWithFlag(0, 1<<uint(bits)) })
+		assert.Panics(t, func() { vec.AddWithFlag(0, -1) })
+		assert.Panics(t, func() { vec.Add(limit + 1) })
+		assert.Panics(t, func() { vec.Add(-limit - 1) })
+	}
+
+	vec := NewVector()
+	vec.AddWithFlag(7, 0)
+	n, f := vec.GetWithFlag(0)
+	assert.Equal(t, 7, n)
+	assert.Equal(t, 0, f)
+	assert.Panics(t, func() { vec.AddWithFlag(7, 1) })
+
+	assert.Panics(t, func() { WithFlags(0) })
+	assert.Panics(t, func() { WithFlags(maxFlagBits + 1) })
+}
+
+func TestFlagsEncodeDecode(t *testing.T) {
+	vec := NewVectorWithOptions(WithFlags(2), WithBase(-100))
+	values := make([]int, 1e4)
+	flags := make([]int, len(values))
+	for i := range values {
+		values[i] = rand.Intn(1e6) - 100
+		flags[i] = rand.Intn(4)
+		vec.AddWithFlag(values[i], flags[i])
+	}
+
+	check := func(nvec *Vector) {
+		for i := range values {
+			n, f := nvec.GetWithFlag(i)
+			if !assert.Equal(t, values[i], n) || !assert.Equal(t, flags[i], f) {
+				break
+			}
+		}
+		assert.Equal(t, vec.ContentHash(), nvec.ContentHash())
+	}
+
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+	nvec := NewVector()
+	assert.Nil(t, nvec.GobDecode(data))
+	check(nvec)
+
+	buf := &bytes.Buffer{}
+	_, err = vec.WriteTo(buf)
+	assert.Nil(t, err)
+	nvec = NewVector()
+	_, err = nvec.ReadFrom(buf)
+	assert.Nil(t, err)
+	check(nvec)
+
+	// Copying the codes keeps the flags
+	// while decoding them drops the flags
+	same := NewVectorWithOptions(WithFlags(2), WithBase(-100))
+	same.Extend(vec)
+	check(same)
+
+	plain := NewVector()
+	plain.Extend(vec)
+	assert.Equal(t, values, plain.ToSlice())
+	_, f := plain.GetWithFlag(0)
+	assert.Equal(t, 0, f)
+	assert.True(t, plain.Equal(vec))
+}
+
+func TestFlagsFreezePartition(t *testing.T) {
+	vec := NewVectorWithOptions(WithFlags(3))
+	values := make([]int, 1e4)
+	flags := make([]int, len(values))
+	for i := range values {
+		values[i] = i - 5e3
+		flags[i] = rand.Intn(8)
+		vec.AddWithFlag(values[i], flags[i])
+	}
+
+	check := func(nvec *Vector, values, flags []int) {
+		assert.Equal(t, len(values), nvec.Len())
+		for i := range values {
+			n, f := nvec.GetWithFlag(i)
+			if !assert.Equal(t, values[i], n) || !assert.Equal(t, flags[i], f) {
+				break
+			}
+		}
+	}
+
+	below, atOrAbove := vec.Partition(5)
+	check(below, values[:5005], flags[:5005])
+	check(atOrAbove, values[5005:], flags[5005:])
+
+	frozen := vec.Clone()
+	frozen.Freeze()
+	assert.True(t, frozen.compact)
+	check(frozen, values, flags)
+	sum, ok := frozen.Sum()
+	assert.True(t, ok)
+	assert.Equal(t, -5000, sum)
+
+	optimized := vec.OptimizeByFrequency()
+	check(optimized, values, flags)
+	assert.Equal(t, len(values), len(optimized.dict))
+}
@@ -0,0 +1,78 @@
+package fibvec
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/robskie/bit"
+	"github.com/stretchr/testify/assert"
+)
+
+// goldenCodes contains the expected encoded values. Each code
+// is the little endian bytes of the bit array produced by adding
+// a single value, without the terminating bits.
+var goldenCodes = []struct {
+	value int
+	bits  int
+	code  string
+}{
+	{0, 3, "03"},
+	{1, 4, "03"},
+	{2, 4, "0b"},
+	{3, 5, "03"},
+	{4, 5, "13"},
+	{5, 5, "0b"},
+	{10, 6, "2b"},
+	{63, 10, "2301"},
+	{64, 10, "a300"},
+	{100, 11, "2300"},
+	{255, 13, "4304"},
+	{256, 13, "4314"},
+	{1000, 16, "0344"},
+	{12345, 21, "432104"},
+	{1 << 20, 30, "13422908"},
+	{1 << 31, 46, "234224489100"},
+	{-1, 92, "a30244448114a84894122202"},
+	{-2, 92, "a30244448114a8489412220a"},
+	{-3, 92, "a30244448114a84894122201"},
+	{-100, 92, "a30244448114a84894121100"},
+	{-12345, 92, "a30244448114a848548a0a02"},
+	{-(1 << 40), 92, "a3024444255494149428420a"},
+	{MaxValue - 1, 92, "a30244448114a84894124201"},
+	{MaxValue, 92, "a30244448114a84894124209"},
+	{MinValue + 1, 93, "4b111491488044a222280a02"},
+	{MinValue, 93, "4b111491488044a222280a12"},
+}
+
+func TestGoldenCodes(t *testing.T) {
+	for _, g := range goldenCodes {
+		fc, lfc := fibencode(toSignMagnitude(g.value))
+		assert.Equal(t, g.bits, lfc, "value %d", g.value)
+
+		code := make([]byte, len(fc)*8)
+		for i, f := range fc {
+			binary.LittleEndian.PutUint64(code[i*8:], f)
+		}
+		code = code[:(lfc+7)/8]
+		assert.Equal(t, g.code, hex.EncodeToString(code), "value %d", g.value)
+
+		// Decode the golden code instead
+		// of the newly encoded one
+		gcode, err := hex.DecodeString(g.code)
+		assert.Nil(t, err)
+
+		array := bit.NewArray(0)
+		for i := 0; i < g.bits; i += 8 {
+			n := g.bits - i
+			if n > 8 {
+				n = 8
+			}
+			array.Add(uint64(gcode[i/8]), n)
+		}
+		array.Add(0x3, 3)
+
+		result := fibdecode(byteSliceFromUint64Slice(array.Bits()), 1)
+		assert.Equal(t, []int{g.value}, result)
+	}
+}
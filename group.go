@@ -0,0 +1,134 @@
+package fibvec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// VectorGroup represents a collection of small vectors.
+// The values of all the vectors are stored in a single
+// vector so that they share its rank and select samples
+// and terminating bits.
+type VectorGroup struct {
+	values *Vector
+
+	// ends[i] is the index in values
+	// after the last value of vector i.
+	ends *DeltaVector
+}
+
+// NewVectorGroup creates a new vector group.
+func NewVectorGroup() *VectorGroup {
+	return &VectorGroup{
+		values: NewVector(),
+		ends:   NewDeltaVector(),
+	}
+}
+
+// AddVector adds a vector containing the given
+// values to the group and returns its id.
+func (g *VectorGroup) AddVector(values []int) int {
+	if g.values == nil {
+		g.values = NewVector()
+		g.ends = NewDeltaVector()
+	}
+
+	for _, n := range values {
+		g.values.Add(n)
+	}
+	g.ends.Add(g.values.Len())
+
+	return g.ends.Len() - 1
+}
+
+// Get returns the ith value of the vector with the given id.
+func (g *VectorGroup) Get(id, i int) int {
+	start, end := g.bounds(id)
+	if i >= end-start {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	return g.values.Get(start + i)
+}
+
+// VectorLen returns the number of values
+// in the vector with the given id.
+func (g *VectorGroup) VectorLen(id int) int {
+	start, end := g.bounds(id)
+	return end - start
+}
+
+// bounds returns the range of indices in
+// values of the vector with the given id.
+func (g *VectorGroup) bounds(id int) (int, int) {
+	if id >= g.Len() || id < 0 {
+		panic("fibvec: invalid vector id")
+	}
+
+	start := 0
+	if id > 0 {
+		start = g.ends.Get(id - 1)
+	}
+
+	return start, g.ends.Get(id)
+}
+
+// Len returns the number of vectors in the group.
+func (g *VectorGroup) Len() int {
+	if g.ends == nil {
+		return 0
+	}
+	return g.ends.Len()
+}
+
+// Size returns the group size in bytes.
+func (g *VectorGroup) Size() int {
+	if g.values == nil {
+		return 0
+	}
+	return g.values.Size() + g.ends.Size()
+}
+
+// GobEncode encodes this group into gob streams.
+func (g *VectorGroup) GobEncode() ([]byte, error) {
+	if g.values == nil {
+		g.values = NewVector()
+		g.ends = NewDeltaVector()
+	}
+
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+
+	err := checkErr(
+		enc.Encode(g.values),
+		enc.Encode(g.ends),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: encode failed (%v)", err)
+	}
+
+	return buf.Bytes(), err
+}
+
+// GobDecode populates this group from gob streams.
+func (g *VectorGroup) GobDecode(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+
+	g.values = NewVector()
+	g.ends = NewDeltaVector()
+	err := checkErr(
+		dec.Decode(g.values),
+		dec.Decode(g.ends),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: decode failed (%v)", err)
+	}
+
+	return err
+}
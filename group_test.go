@@ -0,0 +1,84 @@
+package fibvec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomVectors(n, maxlen int) [][]int {
+	vectors := make([][]int, n)
+	for i := range vectors {
+		vectors[i] = make([]int, rand.Intn(maxlen+1))
+		for j := range vectors[i] {
+			vectors[i][j] = rand.Intn(1e4) - 5e3
+		}
+	}
+
+	return vectors
+}
+
+func TestVectorGroupAddGet(t *testing.T) {
+	group := NewVectorGroup()
+	vectors := randomVectors(1e3, 20)
+	for i, values := range vectors {
+		assert.Equal(t, i, group.AddVector(values))
+	}
+
+	assert.Equal(t, len(vectors), group.Len())
+	for id, values := range vectors {
+		assert.Equal(t, len(values), group.VectorLen(id))
+		for i, v := range values {
+			if !assert.Equal(t, v, group.Get(id, i)) {
+				return
+			}
+		}
+	}
+
+	assert.Panics(t, func() { group.Get(len(vectors), 0) })
+	assert.Panics(t, func() { group.Get(0, -1) })
+	assert.Panics(t, func() { group.Get(0, len(vectors[0])) })
+}
+
+func TestVectorGroupEncodeDecode(t *testing.T) {
+	group := NewVectorGroup()
+	vectors := randomVectors(100, 20)
+	for _, values := range vectors {
+		group.AddVector(values)
+	}
+
+	data, err := group.GobEncode()
+	assert.Nil(t, err)
+
+	ngroup := &VectorGroup{}
+	assert.Nil(t, ngroup.GobDecode(data))
+	assert.Equal(t, group.Len(), ngroup.Len())
+	for id, values := range vectors {
+		for i, v := range values {
+			if !assert.Equal(t, v, ngroup.Get(id, i)) {
+				return
+			}
+		}
+	}
+}
+
+func TestVectorGroupSize(t *testing.T) {
+	group := NewVectorGroup()
+	size := 0
+	for i := 0; i < 1e4; i++ {
+		values := make([]int, rand.Intn(8))
+		for j := range values {
+			values[j] = rand.Intn(100)
+		}
+		group.AddVector(values)
+
+		vec := NewVector()
+		for _, v := range values {
+			vec.Add(v)
+		}
+		size += vec.Size()
+	}
+
+	assert.True(t, group.Size() < size/4)
+}
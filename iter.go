@@ -0,0 +1,151 @@
+package fibvec
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over the
+// indices and values of the vector.
+func (v *Vector) All() iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		d := v.decoder(0)
+		for i := 0; i < v.length; i++ {
+			n, _ := d.next()
+			if !yield(i, n) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values of the
+// vector. Use ToSlice to get all the values as a slice.
+func (v *Vector) Values() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		d := v.decoder(0)
+		for i := 0; i < v.length; i++ {
+			n, _ := d.next()
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// ForEach calls fn for each index and value of the vector
+// in order and stops if fn returns false. fn must not add
+// values to v.
+func (v *Vector) ForEach(fn func(index int, value int) bool) {
+	d := v.decoder(0)
+	for i := 0; i < v.length; i++ {
+		n, _ := d.next()
+		if !fn(i, n) {
+			return
+		}
+	}
+}
+
+// Batches returns an iterator over successive batches of
+// at most size values. The last batch may be shorter. The
+// same buffer is reused for every batch so it must not be
+// retained after the next iteration. This panics if size
+// is less than 1.
+func (v *Vector) Batches(size int) iter.Seq[[]int] {
+	if size < 1 {
+		panic("fibvec: batch size must be greater than zero")
+	}
+
+	return func(yield func([]int) bool) {
+		c := size
+		if c > v.length {
+			c = v.length
+		}
+		buf := make([]int, 0, c)
+
+		d := v.decoder(0)
+		for i := 0; i < v.length; i++ {
+			n, _ := d.next()
+			buf = append(buf, n)
+
+			if len(buf) == size || i == v.length-1 {
+				if !yield(buf) {
+					return
+				}
+				buf = buf[:0]
+			}
+		}
+	}
+}
+
+// StreamValues sends the values from start to end-1 to out
+// and closes it afterwards. This blocks until all the values
+// are received so a slow receiver limits how many values are
+// decoded ahead of it.
+func (v *Vector) StreamValues(start, end int, out chan<- int) {
+	v.StreamValuesContext(context.Background(), start, end, out)
+}
+
+// StreamValuesContext is like StreamValues but stops sending
+// values when ctx is done. This returns the context's error if
+// it stops early. out is closed in both cases.
+func (v *Vector) StreamValuesContext(ctx context.Context, start, end int, out chan<- int) error {
+	defer close(out)
+
+	if end < start {
+		panic("fibvec: end must not be less than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > v.length {
+		panic("fibvec: index out of bounds")
+	} else if end == start {
+		return nil
+	}
+
+	d := v.decoder(start)
+	for i := start; i < end; i++ {
+		n, _ := d.next()
+
+		select {
+		case out <- n:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Iterator reads the values of a vector sequentially.
+// It doesn't modify the vector so several iterators
+// can read the same vector concurrently.
+type Iterator struct {
+	dec       *decoder
+	remaining int
+}
+
+// Iterator returns an iterator that
+// starts from the first value of v.
+func (v *Vector) Iterator() *Iterator {
+	return &Iterator{
+		dec:       v.decoder(0),
+		remaining: v.length,
+	}
+}
+
+// Next returns the next value. This returns
+// false if there are no more values.
+func (it *Iterator) Next() (int, bool) {
+	if it.remaining == 0 {
+		return 0, false
+	}
+
+	n, ok := it.dec.next()
+	if !ok {
+		it.remaining = 0
+		return 0, false
+	}
+	it.remaining--
+
+	return n, true
+}
@@ -0,0 +1,252 @@
+package fibvec
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	var indices, values []int
+	for i, v := range vec.All() {
+		indices = append(indices, i)
+		values = append(values, v)
+	}
+
+	for i := range indices {
+		if !assert.Equal(t, i, indices[i]) {
+			break
+		}
+	}
+	assert.Equal(t, vec.ToSlice(), values)
+
+	for range NewVector().All() {
+		assert.Fail(t, "empty vector must not yield values")
+	}
+}
+
+func TestValues(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	values := []int{}
+	for v := range vec.Values() {
+		values = append(values, v)
+	}
+	assert.Equal(t, vec.ToSlice(), values)
+
+	// Clear the words after the 200th value so
+	// that decoding them logs a code that is too
+	// long, then break early and make sure that
+	// the iterator doesn't decode any further.
+	buf := &bytes.Buffer{}
+	DebugLogger = log.New(buf, "", 0)
+	defer func() { DebugLogger = nil }()
+
+	tvec := vec.Clone()
+	words := tvec.bits.Bits()
+	for i := tvec.select11(201)>>6 + 1; i < len(words); i++ {
+		words[i] = 0
+	}
+
+	decoded := []int{}
+	seq := tvec.Values()
+	seq(func(v int) bool {
+		decoded = append(decoded, v)
+		return len(decoded) < 100
+	})
+	assert.Equal(t, values[:100], decoded)
+	assert.Empty(t, buf.String())
+
+	for range tvec.Values() {
+	}
+	assert.Contains(t, buf.String(), "too long")
+}
+
+func TestForEach(t *testing.T) {
+	NewVector().ForEach(func(int, int) bool {
+		assert.Fail(t, "empty vector must not call fn")
+		return true
+	})
+
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	sum := 0
+	next := 0
+	vec.ForEach(func(i, v int) bool {
+		assert.Equal(t, next, i)
+		next++
+
+		sum += v
+		return true
+	})
+	expected, _ := vec.streamSum()
+	assert.Equal(t, expected, sum)
+	assert.Equal(t, vec.Len(), next)
+
+	last := -1
+	vec.ForEach(func(i, v int) bool {
+		assert.Equal(t, vec.Get(i), v)
+		last = i
+		return i < 500
+	})
+	assert.Equal(t, 500, last)
+}
+
+func TestBatches(t *testing.T) {
+	vec := NewVector()
+	for range vec.Batches(10) {
+		assert.Fail(t, "empty vector must not yield batches")
+	}
+
+	for i := 0; i < 1e4+7; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	for _, size := range []int{1, 10, 1e4, 2e4} {
+		values := []int{}
+		lens := []int{}
+		for batch := range vec.Batches(size) {
+			values = append(values, batch...)
+			lens = append(lens, len(batch))
+		}
+		assert.Equal(t, vec.ToSlice(), values)
+
+		last := vec.Len() % size
+		if last == 0 {
+			last = size
+		}
+		if last > vec.Len() {
+			last = vec.Len()
+		}
+		for _, n := range lens[:len(lens)-1] {
+			assert.Equal(t, size, n)
+		}
+		assert.Equal(t, last, lens[len(lens)-1])
+	}
+
+	assert.Panics(t, func() { vec.Batches(0) })
+}
+
+func TestStreamValues(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	// Consume slowly so that
+	// the sender has to wait
+	out := make(chan int)
+	go vec.StreamValues(100, 5000, out)
+
+	values := []int{}
+	for n := range out {
+		values = append(values, n)
+		if len(values)%500 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	assert.Equal(t, vec.GetValues(100, 5000), values)
+
+	out = make(chan int)
+	go vec.StreamValues(10, 10, out)
+	_, ok := <-out
+	assert.False(t, ok)
+
+	// Stop early
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out = make(chan int)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- vec.StreamValuesContext(ctx, 0, vec.Len(), out)
+	}()
+
+	received := 0
+	for range out {
+		received++
+		if received == 10 {
+			cancel()
+		}
+	}
+	assert.ErrorIs(t, <-errc, context.Canceled)
+	assert.True(t, received < vec.Len())
+
+	assert.Panics(t, func() { vec.StreamValues(2, 1, make(chan int)) })
+}
+
+func TestIterator(t *testing.T) {
+	_, ok := NewVector().Iterator().Next()
+	assert.False(t, ok)
+
+	values := make([]int, 1e5)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+	}
+	vec := NewVectorFromSlice(values)
+
+	it := vec.Iterator()
+	for i, v := range values {
+		n, ok := it.Next()
+		if !assert.True(t, ok) || !assert.Equal(t, v, n, "index %d", i) {
+			break
+		}
+	}
+
+	_, ok = it.Next()
+	assert.False(t, ok)
+	_, ok = it.Next()
+	assert.False(t, ok)
+}
+
+// iteratorBenchVector returns a vector of small
+// values where decoding is cheaper than locating.
+func iteratorBenchVector() *Vector {
+	r := rand.New(rand.NewSource(1))
+
+	values := make([]int, 1e5)
+	for i := range values {
+		values[i] = r.Intn(1e3)
+	}
+
+	return NewVectorFromSlice(values)
+}
+
+func BenchmarkIterator(b *testing.B) {
+	vec := iteratorBenchVector()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := vec.Iterator()
+		for _, ok := it.Next(); ok; _, ok = it.Next() {
+		}
+	}
+}
+
+// BenchmarkIteratorGet reads the
+// values sequentially using Get.
+func BenchmarkIteratorGet(b *testing.B) {
+	vec := iteratorBenchVector()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < vec.Len(); j++ {
+			vec.Get(j)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package fibvec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// PairVector represents a container for pairs of
+// integers. Each component is stored in its own
+// vector so that a and b can be decoded separately.
+type PairVector struct {
+	a *Vector
+	b *Vector
+}
+
+// NewPairVector creates a new pair vector.
+func NewPairVector() *PairVector {
+	return &PairVector{
+		a: NewVector(),
+		b: NewVector(),
+	}
+}
+
+// Add adds a pair of integers to the vector.
+func (v *PairVector) Add(a, b int) {
+	if v.a == nil {
+		v.a = NewVector()
+		v.b = NewVector()
+	}
+
+	v.a.Add(a)
+	v.b.Add(b)
+}
+
+// Get returns the pair at index i.
+func (v *PairVector) Get(i int) (int, int) {
+	if i >= v.Len() {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	return v.a.Get(i), v.b.Get(i)
+}
+
+// Len returns the number of pairs stored.
+func (v *PairVector) Len() int {
+	if v.a == nil {
+		return 0
+	}
+	return v.a.Len()
+}
+
+// Size returns the vector size in bytes.
+func (v *PairVector) Size() int {
+	if v.a == nil {
+		return 0
+	}
+	return v.a.Size() + v.b.Size()
+}
+
+// GobEncode encodes this vector into gob streams.
+func (v *PairVector) GobEncode() ([]byte, error) {
+	if v.a == nil {
+		v.a = NewVector()
+		v.b = NewVector()
+	}
+
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+
+	err := checkErr(
+		enc.Encode(v.a),
+		enc.Encode(v.b),
+	)
+
+	if err == nil && v.a.Len() != v.b.Len() {
+		err = fmt.Errorf("column lengths differ")
+	}
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: encode failed (%v)", err)
+	}
+
+	return buf.Bytes(), err
+}
+
+// GobDecode populates this vector from gob streams.
+func (v *PairVector) GobDecode(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+
+	v.a = NewVector()
+	v.b = NewVector()
+	err := checkErr(
+		dec.Decode(v.a),
+		dec.Decode(v.b),
+	)
+
+	if err == nil && v.a.Len() != v.b.Len() {
+		err = fmt.Errorf("column lengths differ")
+	}
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: decode failed (%v)", err)
+	}
+
+	return err
+}
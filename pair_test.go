@@ -0,0 +1,71 @@
+package fibvec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pair struct {
+	a, b int
+}
+
+func TestPairVectorAddGet(t *testing.T) {
+	vec := NewPairVector()
+	pairs := make([]pair, 1e4)
+	for i := range pairs {
+		pairs[i] = pair{rand.Intn(1e3) - 5e2, rand.Intn(1e6)}
+		vec.Add(pairs[i].a, pairs[i].b)
+	}
+
+	assert.Equal(t, len(pairs), vec.Len())
+	for i, p := range pairs {
+		a, b := vec.Get(i)
+		if !assert.Equal(t, p, pair{a, b}) {
+			break
+		}
+	}
+
+	assert.Panics(t, func() { vec.Get(len(pairs)) })
+	assert.Panics(t, func() { vec.Get(-1) })
+}
+
+func TestPairVectorEncodeDecode(t *testing.T) {
+	vec := NewPairVector()
+	pairs := make([]pair, 1e4)
+	for i := range pairs {
+		pairs[i] = pair{rand.Intn(1e3), rand.Intn(1e6) - 5e5}
+		vec.Add(pairs[i].a, pairs[i].b)
+	}
+
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+
+	nvec := &PairVector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.Equal(t, vec.Len(), nvec.Len())
+	for i, p := range pairs {
+		a, b := nvec.Get(i)
+		if !assert.Equal(t, p, pair{a, b}) {
+			break
+		}
+	}
+}
+
+// TestPairVectorSize checks that storing each
+// component separately takes no more space than
+// interleaving both components in a single vector.
+func TestPairVectorSize(t *testing.T) {
+	vec := NewPairVector()
+	interleaved := NewVector()
+	for i := 0; i < 1e5; i++ {
+		a, b := rand.Intn(10), rand.Intn(1e3)+1e6
+		vec.Add(a, b)
+
+		interleaved.Add(a)
+		interleaved.Add(b)
+	}
+
+	assert.True(t, vec.Size() <= interleaved.Size())
+}
@@ -0,0 +1,119 @@
+package fibvec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+)
+
+// PermutedVector represents an immutable container for
+// integers that stores them in sorted order together with
+// the permutation needed to recover their original order.
+// Sorting makes the differences of successive values small
+// which are then stored in a delta vector.
+type PermutedVector struct {
+	sorted *DeltaVector
+
+	// positions[i] is the index in
+	// sorted of the ith input value
+	positions *Vector
+}
+
+// NewPermutedVector creates a new permuted
+// vector containing the given values.
+func NewPermutedVector(values []int) *PermutedVector {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+
+	positions := make([]int, len(values))
+	sorted := NewDeltaVector()
+	for i, j := range order {
+		sorted.Add(values[j])
+		positions[j] = i
+	}
+
+	vec := &PermutedVector{
+		sorted:    sorted,
+		positions: NewVector(),
+	}
+	for _, p := range positions {
+		vec.positions.Add(p)
+	}
+
+	return vec
+}
+
+// Get returns the value at index i
+// in the original order.
+func (v *PermutedVector) Get(i int) int {
+	if i >= v.Len() {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	return v.sorted.Get(v.positions.Get(i))
+}
+
+// Len returns the number of values stored.
+func (v *PermutedVector) Len() int {
+	if v.positions == nil {
+		return 0
+	}
+	return v.positions.Len()
+}
+
+// Size returns the vector size in bytes.
+func (v *PermutedVector) Size() int {
+	if v.positions == nil {
+		return 0
+	}
+	return v.sorted.Size() + v.positions.Size()
+}
+
+// GobEncode encodes this vector into gob streams.
+func (v *PermutedVector) GobEncode() ([]byte, error) {
+	if v.positions == nil {
+		v.sorted = NewDeltaVector()
+		v.positions = NewVector()
+	}
+
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+
+	err := checkErr(
+		enc.Encode(v.sorted),
+		enc.Encode(v.positions),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: encode failed (%v)", err)
+	}
+
+	return buf.Bytes(), err
+}
+
+// GobDecode populates this vector from gob streams.
+func (v *PermutedVector) GobDecode(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+
+	v.sorted = NewDeltaVector()
+	v.positions = NewVector()
+	err := checkErr(
+		dec.Decode(v.sorted),
+		dec.Decode(v.positions),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: decode failed (%v)", err)
+	}
+
+	return err
+}
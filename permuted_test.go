@@ -0,0 +1,60 @@
+package fibvec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermutedVectorGet(t *testing.T) {
+	assert.Equal(t, 0, NewPermutedVector(nil).Len())
+
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e6) - 5e5
+	}
+
+	vec := NewPermutedVector(values)
+	assert.Equal(t, len(values), vec.Len())
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+
+	assert.Panics(t, func() { vec.Get(len(values)) })
+	assert.Panics(t, func() { vec.Get(-1) })
+}
+
+func TestPermutedVectorEncodeDecode(t *testing.T) {
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e3)
+	}
+
+	data, err := NewPermutedVector(values).GobEncode()
+	assert.Nil(t, err)
+
+	vec := &PermutedVector{}
+	assert.Nil(t, vec.GobDecode(data))
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+}
+
+// TestPermutedVectorCompression checks that the
+// sorted values take less space than unsorted ones.
+func TestPermutedVectorCompression(t *testing.T) {
+	values := make([]int, 1e5)
+	unsorted := NewVector()
+	for i := range values {
+		values[i] = rand.Intn(1e9)
+		unsorted.Add(values[i])
+	}
+
+	vec := NewPermutedVector(values)
+	assert.True(t, vec.sorted.Size() < unsorted.Size()/2)
+}
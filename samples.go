@@ -0,0 +1,174 @@
+package fibvec
+
+import (
+	"math"
+	"math/bits"
+)
+
+// sampleArray contains the rank or select samples of a vector.
+// The samples are stored as uint32s which halves their size
+// compared to ints. Once a sample doesn't fit in a uint32, which
+// only happens in vectors of more than 4 billion bits, all the
+// samples are converted to ints.
+type sampleArray struct {
+	narrow []uint32
+	wide   []int
+}
+
+// makeSamples returns n zero
+// samples with room for capacity.
+func makeSamples(n, capacity int) sampleArray {
+	return sampleArray{narrow: make([]uint32, n, capacity)}
+}
+
+// samplesOf returns the samples in ns. This returns
+// an empty sampleArray if ns is empty so that omitted
+// samples in a decoded vector are rebuilt.
+func samplesOf(ns []int) sampleArray {
+	if len(ns) == 0 {
+		return sampleArray{}
+	}
+
+	s := makeSamples(0, len(ns))
+	for _, n := range ns {
+		s.add(n)
+	}
+
+	return s
+}
+
+// isNil returns true if s
+// has no backing array.
+func (s *sampleArray) isNil() bool {
+	return s.narrow == nil && s.wide == nil
+}
+
+// isWide returns true if the
+// samples are stored as ints.
+func (s *sampleArray) isWide() bool {
+	return s.wide != nil
+}
+
+func (s *sampleArray) length() int {
+	if s.wide != nil {
+		return len(s.wide)
+	}
+	return len(s.narrow)
+}
+
+func (s *sampleArray) capacity() int {
+	if s.wide != nil {
+		return cap(s.wide)
+	}
+	return cap(s.narrow)
+}
+
+func (s *sampleArray) at(i int) int {
+	if s.wide != nil {
+		return s.wide[i]
+	}
+	return int(s.narrow[i])
+}
+
+func (s *sampleArray) set(i, n int) {
+	if s.wide == nil && fitsNarrow(n) {
+		s.narrow[i] = uint32(n)
+		return
+	}
+
+	s.widen()
+	s.wide[i] = n
+}
+
+func (s *sampleArray) add(n int) {
+	if s.wide == nil && fitsNarrow(n) {
+		s.narrow = append(s.narrow, uint32(n))
+		return
+	}
+
+	s.widen()
+	s.wide = append(s.wide, n)
+}
+
+// truncate keeps the first n samples.
+func (s *sampleArray) truncate(n int) {
+	if s.wide != nil {
+		s.wide = s.wide[:n]
+	} else {
+		s.narrow = s.narrow[:n]
+	}
+}
+
+// reserve grows the capacity of s
+// to at least n if it is smaller.
+func (s *sampleArray) reserve(n int) {
+	if s.capacity() >= n {
+		return
+	}
+
+	if s.wide != nil {
+		wide := make([]int, len(s.wide), n)
+		copy(wide, s.wide)
+		s.wide = wide
+	} else {
+		narrow := make([]uint32, len(s.narrow), n)
+		copy(narrow, s.narrow)
+		s.narrow = narrow
+	}
+}
+
+// widen converts the samples to ints.
+func (s *sampleArray) widen() {
+	if s.wide != nil {
+		return
+	}
+
+	s.wide = make([]int, len(s.narrow), cap(s.narrow))
+	for i, n := range s.narrow {
+		s.wide[i] = int(n)
+	}
+	s.narrow = nil
+}
+
+// clone returns a copy of s that
+// doesn't share its backing array.
+func (s *sampleArray) clone() sampleArray {
+	if s.isNil() {
+		return sampleArray{}
+	} else if s.wide != nil {
+		return sampleArray{wide: append([]int{}, s.wide...)}
+	}
+
+	return sampleArray{narrow: append([]uint32{}, s.narrow...)}
+}
+
+// ints returns the samples as ints.
+func (s *sampleArray) ints() []int {
+	if s.isNil() {
+		return nil
+	} else if s.wide != nil {
+		return append([]int{}, s.wide...)
+	}
+
+	ns := make([]int, len(s.narrow))
+	for i, n := range s.narrow {
+		ns[i] = int(n)
+	}
+
+	return ns
+}
+
+// size returns the size of
+// the samples in bytes.
+func (s *sampleArray) size() int {
+	if s.wide != nil {
+		return len(s.wide) * bits.UintSize / 8
+	}
+	return len(s.narrow) * 4
+}
+
+// fitsNarrow returns true if
+// n can be stored in a uint32.
+func fitsNarrow(n int) bool {
+	return n >= 0 && n <= math.MaxUint32
+}
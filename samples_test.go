@@ -0,0 +1,104 @@
+package fibvec
+
+import (
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleArray(t *testing.T) {
+	s := makeSamples(2, 4)
+	assert.False(t, s.isNil())
+	assert.False(t, s.isWide())
+	assert.Equal(t, 2, s.length())
+	assert.Equal(t, 4, s.capacity())
+
+	s.set(1, 7)
+	s.add(wideSample - 1)
+	assert.False(t, s.isWide())
+	assert.Equal(t, []int{0, 7, wideSample - 1}, s.ints())
+	assert.Equal(t, 12, s.size())
+
+	// Samples that don't fit in
+	// a uint32 widen the array
+	c := s.clone()
+	s.add(wideSample)
+	assert.True(t, s.isWide())
+	assert.Equal(t, []int{0, 7, wideSample - 1, wideSample}, s.ints())
+	assert.Equal(t, 4*bits.UintSize/8, s.size())
+	assert.Equal(t, []int{0, 7, wideSample - 1}, c.ints())
+
+	c.set(0, -1)
+	assert.True(t, c.isWide())
+	assert.Equal(t, -1, c.at(0))
+	assert.Equal(t, 7, c.at(1))
+
+	s.truncate(1)
+	assert.Equal(t, []int{0}, s.ints())
+	s.reserve(100)
+	assert.Equal(t, 100, s.capacity())
+	assert.Equal(t, 1, s.length())
+
+	n := makeSamples(1, 1)
+	n.reserve(10)
+	assert.Equal(t, 10, n.capacity())
+	assert.False(t, n.isWide())
+
+	empty := samplesOf(nil)
+	assert.True(t, empty.isNil())
+	assert.Nil(t, empty.ints())
+	empty = empty.clone()
+	assert.True(t, empty.isNil())
+	mixed := samplesOf([]int{1, wideSample})
+	assert.Equal(t, []int{1, wideSample}, mixed.ints())
+}
+
+func TestWideSamples(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e6) - 5e5
+		vec.Add(values[i])
+	}
+
+	// Widened samples give the same results
+	ranks, indices := vec.ranks.ints(), vec.indices.ints()
+	vec.ranks.widen()
+	vec.indices.widen()
+	assert.Equal(t, ranks, vec.ranks.ints())
+	assert.Equal(t, indices, vec.indices.ints())
+	for i := 0; i < 1e3; i++ {
+		vec.Add(i)
+		values = append(values, i)
+	}
+	assert.True(t, vec.ranks.isWide())
+	assert.Equal(t, values, vec.ToSlice())
+	for i := 0; i < 100; i++ {
+		k := rand.Intn(len(values))
+		assert.Equal(t, values[k], vec.Get(k))
+	}
+
+	// The decoded samples
+	// use the narrow width
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+	nvec := NewVector()
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.False(t, nvec.ranks.isWide())
+	assert.Equal(t, vec.ranks.ints(), nvec.ranks.ints())
+	assert.Equal(t, values, nvec.ToSlice())
+
+	data, err = vec.MarshalBinary()
+	assert.Nil(t, err)
+	nvec = NewVector()
+	assert.Nil(t, nvec.UnmarshalBinary(data))
+	assert.False(t, nvec.indices.isWide())
+	assert.Equal(t, vec.indices.ints(), nvec.indices.ints())
+	assert.Equal(t, values, nvec.ToSlice())
+}
+
+// wideSample is the smallest sample
+// that doesn't fit in a uint32.
+const wideSample = 1 << 32
@@ -0,0 +1,82 @@
+package fibvec
+
+import (
+	"fmt"
+
+	"github.com/robskie/bit"
+)
+
+// SelfTest checks that the encoding and decoding tables
+// are consistent by encoding and decoding a fixed set of
+// values. These include small values, values around the
+// fibonacci numbers and powers of 2, and the extremes.
+// This returns an error containing the first value that
+// isn't decoded back to itself.
+func SelfTest() error {
+	values := selfTestValues()
+
+	array := bit.NewArray(0)
+	for _, n := range values {
+		fc, lfc := fibencode(toSignMagnitude(n))
+		if lfc != EncodedLen(n) {
+			return fmt.Errorf("fibvec: self test failed for %d (wrong code length)", n)
+		}
+
+		for _, f := range fc[:len(fc)-1] {
+			array.Add(f, 64)
+			lfc -= 64
+		}
+		array.Add(fc[len(fc)-1], lfc)
+	}
+	array.Add(0x3, 3)
+
+	result := fibdecode(byteSliceFromUint64Slice(array.Bits()), len(values))
+	for i, n := range values {
+		if i >= len(result) || result[i] != n {
+			return fmt.Errorf("fibvec: self test failed for %d", n)
+		}
+	}
+
+	vec := NewVectorFromSlice(values)
+	for i, n := range values {
+		if vec.Get(i) != n {
+			return fmt.Errorf("fibvec: self test failed for %d (Get)", n)
+		}
+	}
+
+	return nil
+}
+
+// selfTestValues returns the values used by SelfTest.
+func selfTestValues() []int {
+	values := []int{MaxValue, MaxValue - 1, MinValue, MinValue + 1}
+	add := func(n int) {
+		if n <= MaxValue && n >= MinValue {
+			values = append(values, n, -n)
+		}
+	}
+
+	for n := 0; n < 1000; n++ {
+		add(n)
+	}
+
+	// Encoded values are offset by 2
+	// so include values whose codes
+	// are around fibonacci numbers.
+	for _, f := range fib {
+		if f > MaxValue {
+			break
+		}
+		for d := -3; d <= 1; d++ {
+			add(int(f) + d)
+		}
+	}
+
+	for i := uint(10); i < 63; i++ {
+		add(1<<i - 1)
+		add(1 << i)
+		add(1<<i + 1)
+	}
+
+	return values
+}
@@ -0,0 +1,11 @@
+package fibvec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTest(t *testing.T) {
+	assert.Nil(t, SelfTest())
+}
@@ -0,0 +1,137 @@
+package fibvec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// SparseVector represents a container for integers where
+// most of the values are equal to a default value. Only
+// the values that differ from the default are stored
+// together with their indices.
+type SparseVector struct {
+	// indices contains the indices of the
+	// stored values in increasing order.
+	indices *DeltaVector
+	values  *Vector
+
+	def    int
+	length int
+}
+
+// NewSparseVector creates a new sparse vector
+// with def as the value of the absent entries.
+func NewSparseVector(def int) *SparseVector {
+	return &SparseVector{
+		indices: NewDeltaVector(),
+		values:  NewVector(),
+		def:     def,
+	}
+}
+
+// Add adds an integer to the vector.
+func (v *SparseVector) Add(n int) {
+	if v.indices == nil {
+		v.indices = NewDeltaVector()
+		v.values = NewVector()
+	}
+
+	if n != v.def {
+		v.indices.Add(v.length)
+		v.values.Add(n)
+	}
+	v.length++
+}
+
+// Get returns the value at index i. This
+// returns the default value if it is absent.
+func (v *SparseVector) Get(i int) int {
+	if i >= v.length {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	nvalues := v.values.Len()
+	j := sort.Search(nvalues, func(k int) bool {
+		return v.indices.Get(k) >= i
+	})
+
+	if j < nvalues && v.indices.Get(j) == i {
+		return v.values.Get(j)
+	}
+
+	return v.def
+}
+
+// Default returns the value of the absent entries.
+func (v *SparseVector) Default() int {
+	return v.def
+}
+
+// Len returns the number of values stored
+// including the absent ones.
+func (v *SparseVector) Len() int {
+	return v.length
+}
+
+// Size returns the vector size in bytes.
+func (v *SparseVector) Size() int {
+	sizeofInt := bits.UintSize / 8
+
+	size := 2 * sizeofInt
+	if v.indices != nil {
+		size += v.indices.Size()
+		size += v.values.Size()
+	}
+
+	return size
+}
+
+// GobEncode encodes this vector into gob streams.
+func (v *SparseVector) GobEncode() ([]byte, error) {
+	if v.indices == nil {
+		v.indices = NewDeltaVector()
+		v.values = NewVector()
+	}
+
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+
+	err := checkErr(
+		enc.Encode(v.indices),
+		enc.Encode(v.values),
+		enc.Encode(v.def),
+		enc.Encode(v.length),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: encode failed (%v)", err)
+	}
+
+	return buf.Bytes(), err
+}
+
+// GobDecode populates this vector from gob streams.
+func (v *SparseVector) GobDecode(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+
+	v.indices = NewDeltaVector()
+	v.values = NewVector()
+	err := checkErr(
+		dec.Decode(v.indices),
+		dec.Decode(v.values),
+		dec.Decode(&v.def),
+		dec.Decode(&v.length),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("fibvec: decode failed (%v)", err)
+	}
+
+	return err
+}
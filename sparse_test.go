@@ -0,0 +1,78 @@
+package fibvec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sparseValues returns n values where
+// 90% of them are equal to def.
+func sparseValues(n, def int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = def
+		if rand.Intn(10) == 0 {
+			values[i] = rand.Intn(1e6) - 5e5
+		}
+	}
+
+	return values
+}
+
+func TestSparseVectorAddGet(t *testing.T) {
+	const def = -1
+
+	vec := NewSparseVector(def)
+	values := sparseValues(1e4, def)
+	for _, v := range values {
+		vec.Add(v)
+	}
+
+	assert.Equal(t, def, vec.Default())
+	assert.Equal(t, len(values), vec.Len())
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+
+	assert.Panics(t, func() { vec.Get(len(values)) })
+	assert.Panics(t, func() { vec.Get(-1) })
+}
+
+func TestSparseVectorEncodeDecode(t *testing.T) {
+	const def = 7
+
+	vec := NewSparseVector(def)
+	values := sparseValues(1e4, def)
+	for _, v := range values {
+		vec.Add(v)
+	}
+
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+
+	nvec := &SparseVector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.Equal(t, vec.Len(), nvec.Len())
+	for i, v := range values {
+		if !assert.Equal(t, v, nvec.Get(i)) {
+			break
+		}
+	}
+}
+
+func TestSparseVectorCompression(t *testing.T) {
+	const def = 1e6
+
+	sparse := NewSparseVector(def)
+	dense := NewVector()
+	for _, v := range sparseValues(1e5, def) {
+		sparse.Add(v)
+		dense.Add(v)
+	}
+
+	assert.True(t, sparse.Size() < dense.Size()/2)
+}
@@ -0,0 +1,41 @@
+package fibvec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NewVectorFromTextReader creates a vector from the integers
+// read from r. The integers must be separated by whitespace or
+// newlines. This returns an error containing the line number if
+// an invalid or unencodable integer is encountered.
+func NewVectorFromTextReader(r io.Reader) (*Vector, error) {
+	vec := NewVector()
+
+	// Read whole lines instead of using a
+	// bufio.Scanner which fails on lines
+	// longer than its maximum token size
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		text, err := br.ReadString('\n')
+		for _, field := range strings.Fields(text) {
+			n, err := strconv.Atoi(field)
+			if err != nil || n > MaxValue || n < MinValue {
+				return nil, fmt.Errorf("fibvec: invalid integer %q on line %d", field, line)
+			}
+
+			vec.Add(n)
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("fibvec: read failed (%v)", err)
+		}
+	}
+
+	return vec, nil
+}
@@ -0,0 +1,61 @@
+package fibvec
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVectorFromTextReader(t *testing.T) {
+	vec, err := NewVectorFromTextReader(strings.NewReader(""))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, vec.Len())
+
+	input := "1 2 3\n-4\t5\n\n  -6  \n"
+	vec, err = NewVectorFromTextReader(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3, -4, 5, -6}, vec.ToSlice())
+
+	input = "1 2 3\n4 five 6\n7\n"
+	_, err = NewVectorFromTextReader(strings.NewReader(input))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "line 2")
+		assert.Contains(t, err.Error(), "five")
+	}
+
+	input = "1\n2\n9223372036854775807\n"
+	_, err = NewVectorFromTextReader(strings.NewReader(input))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "line 3")
+	}
+
+	// Lines longer than the default
+	// bufio.Scanner token size
+	values := make([]int, 3e4)
+	fields := make([]string, len(values))
+	for i := range values {
+		values[i] = i * 7
+		fields[i] = strconv.Itoa(values[i])
+	}
+	input = strings.Join(fields, " ") + "\n1\r\n" + strings.Join(fields, "\t") + " x"
+	assert.True(t, len(input) > 2*bufio.MaxScanTokenSize)
+	_, err = NewVectorFromTextReader(strings.NewReader(input))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "line 3")
+	}
+	vec, err = NewVectorFromTextReader(strings.NewReader(input[:len(input)-2]))
+	assert.Nil(t, err)
+	assert.Equal(t, len(values)*2+1, vec.Len())
+	assert.Equal(t, 1, vec.Get(len(values)))
+	assert.Equal(t, values, vec.GetValues(len(values)+1, vec.Len()))
+
+	_, err = NewVectorFromTextReader(iotest.ErrReader(errors.New("broken")))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "broken")
+	}
+}
@@ -0,0 +1,116 @@
+package fibvec
+
+// DeltaEncode returns a vector that contains the differences
+// of the successive values of v, ie., the first value is the
+// same with v and the ith value is v.Get(i) - v.Get(i-1). This
+// is useful for compressing sorted values. The result has the
+// same sampling options as v but no base, dictionary, or flags.
+// This panics if one of the differences overflows an int or is
+// not encodable.
+func (v *Vector) DeltaEncode() *Vector {
+	vec := newPlainVectorLike(v)
+
+	prev := 0
+	for n := range v.Values() {
+		d, ok := addInt(n, -prev)
+		if !ok {
+			panic("fibvec: difference overflows int")
+		}
+
+		vec.Add(d)
+		prev = n
+	}
+
+	return vec
+}
+
+// DeltaDecode reverses DeltaEncode, ie., it returns
+// a vector containing the running sums of v. This panics
+// if one of the sums overflows an int or is not encodable.
+func (v *Vector) DeltaDecode() *Vector {
+	vec := newPlainVectorLike(v)
+
+	sum := 0
+	for n := range v.Values() {
+		s, ok := addInt(sum, n)
+		if !ok {
+			panic("fibvec: sum overflows int")
+		}
+
+		sum = s
+		vec.Add(sum)
+	}
+
+	return vec
+}
+
+// Interleave returns a vector containing the values of a and b
+// in alternating order, ie., a[0], b[0], a[1], b[1]... If one
+// vector is longer, its remaining values are added at the end.
+func Interleave(a, b *Vector) *Vector {
+	vec := NewVector()
+
+	da := a.decoder(0)
+	db := b.decoder(0)
+	for i := 0; i < a.length || i < b.length; i++ {
+		if i < a.length {
+			n, _ := da.next()
+			vec.Add(n)
+		}
+		if i < b.length {
+			n, _ := db.next()
+			vec.Add(n)
+		}
+	}
+
+	return vec
+}
+
+// ZipWith returns a vector whose ith value is op(a, b) where
+// a and b are the ith values of v and other. Both vectors are
+// decoded in a single pass. This panics if the vectors have
+// different lengths or if one of the results is not encodable.
+func (v *Vector) ZipWith(other *Vector, op func(a, b int) int) *Vector {
+	if v.length != other.length {
+		panic("fibvec: vectors have different lengths")
+	}
+
+	vec := NewVector()
+
+	da := v.decoder(0)
+	db := other.decoder(0)
+	for i := 0; i < v.length; i++ {
+		a, _ := da.next()
+		b, _ := db.next()
+		vec.Add(op(a, b))
+	}
+
+	return vec
+}
+
+// MergeInsert returns a sorted vector containing the values of v
+// and sortedNew. Both v and sortedNew must be sorted in ascending
+// order. Duplicates are kept with the values of v coming first.
+// The result has the same sampling options as v but no base,
+// dictionary, or flags. This panics if sortedNew is not sorted.
+func (v *Vector) MergeInsert(sortedNew []int) *Vector {
+	for i := 1; i < len(sortedNew); i++ {
+		if sortedNew[i] < sortedNew[i-1] {
+			panic("fibvec: input is not sorted")
+		}
+	}
+
+	vec := newPlainVectorLike(v)
+
+	j := 0
+	for n := range v.Values() {
+		for j < len(sortedNew) && sortedNew[j] < n {
+			vec.Add(sortedNew[j])
+			j++
+		}
+		vec.Add(n)
+	}
+	vec.AddBatch(sortedNew[j:])
+
+	return vec
+}
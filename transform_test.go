@@ -0,0 +1,166 @@
+package fibvec
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeltaEncodeDecode(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	dvec := vec.DeltaEncode().DeltaDecode()
+	assert.Equal(t, vec.ToSlice(), dvec.ToSlice())
+	assert.Equal(t, 0, NewVector().DeltaEncode().Len())
+
+	// Differences that don't fit in an int
+	// or that are not encodable panic
+	vec = NewVector()
+	vec.Add(MaxValue)
+	vec.Add(MinValue)
+	assert.Panics(t, func() { vec.DeltaEncode() })
+	vec = NewVector()
+	vec.Add(-1)
+	vec.Add(MaxValue)
+	assert.Panics(t, func() { vec.DeltaEncode() })
+
+	vec = NewVector()
+	vec.Add(MaxValue)
+	vec.Add(MaxValue)
+	assert.Panics(t, func() { vec.DeltaDecode() })
+
+	// The sampling options of v are kept
+	vec = NewVectorWithOptions(WithRankBlock(128))
+	vec.Add(3)
+	vec.Add(7)
+	dvec = vec.DeltaEncode()
+	assert.Equal(t, 128, dvec.sr)
+	assert.Equal(t, []int{3, 4}, dvec.ToSlice())
+
+	// but not the base and dictionary
+	// which may not fit the differences
+	values := []int{1500, 1000, 1200, 1000, 1500}
+	bvec := NewVectorWithOptions(WithBase(1000))
+	bvec.AddBatch(values)
+	dvec = bvec.DeltaEncode()
+	assert.Equal(t, []int{1500, -500, 200, -200, 500}, dvec.ToSlice())
+	assert.Equal(t, values, dvec.DeltaDecode().ToSlice())
+
+	fvec := NewVectorFromSlice(values).OptimizeByFrequency()
+	dvec = fvec.DeltaEncode()
+	assert.Equal(t, []int{1500, -500, 200, -200, 500}, dvec.ToSlice())
+	assert.Equal(t, values, dvec.DeltaDecode().ToSlice())
+	assert.Equal(t, values, fvec.DeltaDecode().DeltaEncode().ToSlice())
+}
+
+// TestDeltaCompression calculates the space
+// saved by delta encoding sorted values.
+func TestDeltaCompression(t *testing.T) {
+	vec := NewVector()
+	sum := 0
+	for i := 0; i < 1e5; i++ {
+		sum += rand.Intn(1e3)
+		vec.Add(sum)
+	}
+
+	dvec := vec.DeltaEncode()
+	assert.True(t, dvec.Size() < vec.Size())
+
+	size := float64(vec.Size())
+	dsize := float64(dvec.Size())
+	percentage := ((size - dsize) / size) * 100
+	fmt.Printf("=== DELTA COMPRESSION: %.2f%%\n", percentage)
+}
+
+func TestInterleave(t *testing.T) {
+	sizes := [][2]int{{0, 0}, {100, 100}, {100, 50}, {0, 30}}
+	for _, size := range sizes {
+		a := NewVector()
+		b := NewVector()
+		for i := 0; i < size[0]; i++ {
+			a.Add(rand.Intn(1e3))
+		}
+		for i := 0; i < size[1]; i++ {
+			b.Add(-rand.Intn(1e3))
+		}
+
+		av := a.ToSlice()
+		bv := b.ToSlice()
+		expected := []int{}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			if i < len(av) {
+				expected = append(expected, av[i])
+			}
+			if i < len(bv) {
+				expected = append(expected, bv[i])
+			}
+		}
+
+		assert.Equal(t, expected, Interleave(a, b).ToSlice())
+		assert.Equal(t, len(expected), Interleave(b, a).Len())
+	}
+}
+
+func TestZipWith(t *testing.T) {
+	a := make([]int, 1e4)
+	b := make([]int, len(a))
+	expected := make([]int, len(a))
+	for i := range a {
+		a[i] = rand.Intn(2e6) - 1e6
+		b[i] = rand.Intn(2e6) - 1e6
+		expected[i] = a[i] + b[i]
+	}
+
+	va := NewVectorFromSlice(a)
+	vb := NewVectorFromSlice(b)
+	sum := va.ZipWith(vb, func(x, y int) int { return x + y })
+	assert.Equal(t, expected, sum.ToSlice())
+
+	empty := NewVector().ZipWith(NewVector(), func(x, y int) int { return x ^ y })
+	assert.Equal(t, 0, empty.Len())
+
+	assert.Panics(t, func() {
+		va.ZipWith(NewVectorFromSlice(b[1:]), func(x, y int) int { return x - y })
+	})
+}
+
+func TestMergeInsert(t *testing.T) {
+	sizes := [][2]int{{1e3, 1e3}, {0, 100}, {100, 0}, {0, 0}}
+	for _, size := range sizes {
+		a := make([]int, size[0])
+		for i := range a {
+			a[i] = rand.Intn(100) - 50
+		}
+		sort.Ints(a)
+
+		b := make([]int, size[1])
+		for i := range b {
+			b[i] = rand.Intn(100) - 50
+		}
+		sort.Ints(b)
+
+		expected := append(append([]int{}, a...), b...)
+		sort.Ints(expected)
+
+		merged := NewVectorFromSlice(a).MergeInsert(b)
+		assert.Equal(t, expected, merged.ToSlice())
+	}
+
+	vec := NewVectorFromSlice([]int{1, 3, 3, 5})
+	assert.Equal(t, []int{0, 1, 2, 3, 3, 3, 5, 5, 9}, vec.MergeInsert([]int{0, 2, 3, 5, 9}).ToSlice())
+	assert.Panics(t, func() { vec.MergeInsert([]int{2, 1}) })
+
+	// New values don't have to be in
+	// the dictionary or above the base
+	dvec := vec.OptimizeByFrequency()
+	assert.Equal(t, []int{0, 1, 2, 3, 3, 3, 5, 5, 9}, dvec.MergeInsert([]int{0, 2, 3, 5, 9}).ToSlice())
+	bvec := NewVectorWithOptions(WithBase(1))
+	bvec.AddBatch([]int{1, 3, 3, 5})
+	assert.Equal(t, []int{-1, 1, 3, 3, 5}, bvec.MergeInsert([]int{-1}).ToSlice())
+}
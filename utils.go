@@ -1,9 +1,8 @@
 package fibvec
 
 import (
+	"encoding/binary"
 	"math"
-	"reflect"
-	"unsafe"
 
 	"github.com/robskie/bit"
 )
@@ -14,6 +13,14 @@ const (
 	MinValue = -MaxValue
 )
 
+// maxCodeBits is the maximum length of an encoded
+// value while maxCodeBytes is the maximum number of
+// bytes spanned by an encoded value.
+const (
+	maxCodeBits  = 93
+	maxCodeBytes = 13
+)
+
 type decRecord struct {
 	// shift contains the size of
 	// the partially decoded value
@@ -111,18 +118,128 @@ func fibencode(n uint) ([]uint64, int) {
 	return res.Bits(), res.Len()
 }
 
+// EncodedLen returns the number of bits used to
+// store n in a vector without a base, excluding any
+// padding bits.
+func EncodedLen(n int) int {
+	if n > MaxValue || n < MinValue {
+		panic("fibvec: input is not in the range of encodable values")
+	}
+
+	// The code is a 1 followed by the fibonacci code
+	// of n+2 whose length is k-1 if fib[k-1] is the
+	// largest fibonacci number less than or equal to it.
+	m := toSignMagnitude(n) + 2
+	k := 1
+	for fib[k] <= m {
+		k++
+	}
+
+	return k
+}
+
+// BytesForValue returns the number of bytes used
+// to store a nonnegative value n in a vector without
+// a base. This is EncodedLen(n) divided by 8.
+func BytesForValue(n uint64) float64 {
+	if n > MaxValue {
+		panic("fibvec: input is not in the range of encodable values")
+	}
+
+	return float64(EncodedLen(int(n))) / 8
+}
+
+// EncodeFib returns the fibonacci code of n as used by the
+// vector, followed by the terminating bits that mark its end.
+// The code is a 1 followed by the Zeckendorf representation of
+// n+2 from its largest term down to the smallest, so it always
+// begins with 110 and never contains another pair of 1s. The bits
+// are packed starting from the least significant bit of the first
+// byte and the unused bits of the last byte are zero. This panics
+// if n is greater than MaxValue.
+func EncodeFib(n uint64) []byte {
+	if n > MaxValue {
+		panic("fibvec: input is not in the range of encodable values")
+	}
+
+	fc, lfc := fibencode(uint(n))
+	array := bit.NewArray(lfc + 3)
+	for _, f := range fc[:len(fc)-1] {
+		array.Add(f, 64)
+		lfc -= 64
+	}
+	array.Add(fc[len(fc)-1], lfc)
+	array.Add(0x3, 3)
+
+	bytes := byteSliceFromUint64Slice(array.Bits())
+	return append([]byte(nil), bytes[:(array.Len()+7)>>3]...)
+}
+
+// DecodeFib decodes up to count values from b which contains
+// consecutive codes in the format produced by EncodeFib, that
+// is, packed one after the other without gaps and followed by
+// the terminating bits. Fewer values are returned if decoding
+// reaches the terminating bits or an invalid code.
+func DecodeFib(b []byte, count int) []uint64 {
+	if count < 0 {
+		panic("fibvec: count must not be negative")
+	}
+
+	values := fibdecode(b, count)
+	result := make([]uint64, len(values))
+	for i, n := range values {
+		result[i] = uint64(toSignMagnitude(n))
+	}
+
+	return result
+}
+
 // fibdecode decodes the input bytes given the
 // number of decoded values to return.
 //
 // See Fast decoding algorithms for variable-length codes
 // and Fast Fibonacci Decompression Algorithm by Platos et al.
 func fibdecode(input []byte, count int) []int {
-	prevIn := input[0]
-	fbuffer := make([]byte, 0, 16)
+	return fibdecodeAppend(make([]int, 0, count), input, count)
+}
+
+// fibdecodeAppend is like fibdecode but appends
+// the decoded values to dst instead of a new slice.
+func fibdecodeAppend(dst []int, input []byte, count int) []int {
+	d := decoder{}
+	d.reset(input, 0)
+
+	for i := 0; i < count; i++ {
+		n, ok := d.next()
+		if !ok {
+			break
+		}
+		dst = append(dst, n)
+	}
+
+	return dst
+}
+
+// fibdecodeOne is like fibdecode but only decodes the first
+// value. This returns the sign-magnitude representation of the
+// value, or 0 and false if there's no valid value in input.
+// Like fibdecode, input is treated as if it is followed by two
+// zero bytes.
+func fibdecodeOne(input []byte) (uint, bool) {
+	var fbuffer [maxCodeBytes + 1]byte
+	nbuf := 0
+
+	prevIn := byte(0)
+	if len(input) > 0 {
+		prevIn = input[0]
+	}
 	prevRec := fdecTable[0][prevIn]
-	result := make([]int, 0, count)
+	for pos := 1; pos < len(input)+2; pos++ {
+		in := byte(0)
+		if pos < len(input) {
+			in = input[pos]
+		}
 
-	for _, in := range input[1:] {
 		startWithOne := false
 		endWithOne := prevIn&0x80 != 0
 
@@ -135,47 +252,273 @@ func fibdecode(input []byte, count int) []int {
 
 		shift := int(prevRec.shift)
 		if shift > 0 {
-			fbuffer = append(fbuffer, prevRec.incomplete)
+			if nbuf == len(fbuffer) {
+				return 0, false
+			}
+			fbuffer[nbuf] = prevRec.incomplete
+			nbuf++
 		}
 
-		dec := uint(0)
 		for _, num := range prevRec.numbers {
 			if shift == 0 {
-				dec = decodeBuffer(fbuffer, 8)
-			} else {
-				dec = decodeBuffer(fbuffer, shift)
+				shift = 8
 			}
-			fbuffer = fbuffer[:0]
-
+			dec := decodeBuffer(fbuffer[:nbuf], shift)
 			if dec > 1 {
-				// Subtract 2 to cancel out
-				// what is added during encoding
-				result = append(result, fromSignMagnitude(dec-2))
-				if len(result) == count {
-					return result
-				}
+				return checkDecoded(dec)
 			}
 
 			shift = 0
-			fbuffer = append(fbuffer, num)
+			fbuffer[0] = num
+			nbuf = 1
 		}
 
 		if startWithOne && endWithOne {
-			dec = decodeBuffer(fbuffer, 7)
-			fbuffer = fbuffer[:0]
-
+			dec := decodeBuffer(fbuffer[:nbuf], 7)
 			if dec > 1 {
-				result = append(result, fromSignMagnitude(dec-2))
-				if len(result) == count {
-					return result
-				}
+				return checkDecoded(dec)
 			}
+			nbuf = 0
 		}
 
 		prevRec = rec
 	}
 
-	return result
+	return 0, false
+}
+
+// checkDecoded cancels out what is added to the
+// decoded value dec during encoding. This returns
+// false if the result is out of range.
+func checkDecoded(dec uint) (uint, bool) {
+	const mask = ^(^uint(0) >> 1)
+
+	dec -= 2
+	if dec&^mask > MaxValue {
+		return 0, false
+	}
+
+	return dec, true
+}
+
+// decoder decodes fibonacci coded values one at a
+// time. The input is treated as if it is followed by
+// two zero bytes so that the last value is always
+// decoded.
+type decoder struct {
+	input []byte
+	pos   int
+
+	prevIn  byte
+	prevRec decRecord
+
+	// fbuffer[:nbuf] contains the bytes of
+	// the value that is being decoded.
+	fbuffer [maxCodeBytes + 1]byte
+	nbuf    int
+
+	// base is added to every decoded value
+	// unless dict is set in which case the
+	// decoded value is an index into dict.
+	base int
+	dict []int
+
+	// flagBits is the number of low bits
+	// of each value that contain its flag.
+	flagBits uint
+
+	// invalid is true if the decoder
+	// encountered an invalid code.
+	invalid bool
+
+	// values[head:tail] contains the decoded
+	// values that are not yet returned by next.
+	values [8]int
+	head   int
+	tail   int
+
+	// words[wpos:] contains the words that are
+	// not yet copied into chunk, the input of
+	// the decoder, if the bytes of the words
+	// can't be aliased.
+	words []uint64
+	wpos  int
+	chunk []byte
+}
+
+// decChunk is the number of words that are copied
+// into the input of a decoder at a time if the bytes
+// of the words can't be aliased.
+const decChunk = 16
+
+// reset makes the decoder start decoding from
+// the beginning of input ignoring its first
+// skip bits.
+func (d *decoder) reset(input []byte, skip uint) {
+	d.input = input
+	d.pos = 1
+	d.prevIn = 0
+	if len(input) > 0 {
+		d.prevIn = input[0] & ^byte((1<<skip)-1)
+	}
+	d.prevRec = fdecTable[0][d.prevIn]
+
+	d.nbuf = 0
+
+	d.head = 0
+	d.tail = 0
+	d.invalid = false
+}
+
+// resetWords makes the decoder start decoding
+// from bit idx of words. If the bytes of the words
+// can't be aliased, only the words that are needed
+// are copied into the input as decoding proceeds.
+func (d *decoder) resetWords(words []uint64, idx int) {
+	if bytesAliased {
+		d.words = nil
+		d.reset(byteTail(byteSliceFromUint64Slice(words), idx>>3), uint(idx&7))
+		return
+	}
+
+	d.words = words
+	d.wpos = idx >> 6
+	d.fill()
+	d.reset(byteTail(d.input, (idx>>3)&7), uint(idx&7))
+}
+
+// fill copies the next chunk of
+// words into the decoder input.
+func (d *decoder) fill() {
+	if d.chunk == nil {
+		d.chunk = make([]byte, decChunk*8)
+	}
+
+	n := 0
+	for ; n < decChunk && d.wpos < len(d.words); n++ {
+		binary.LittleEndian.PutUint64(d.chunk[n*8:], d.words[d.wpos])
+		d.wpos++
+	}
+
+	d.input = d.chunk[:n*8]
+	d.pos = 0
+}
+
+// next returns the next decoded value.
+// This returns false if the input is
+// already exhausted or if an invalid
+// code is encountered.
+func (d *decoder) next() (int, bool) {
+	for d.head == d.tail {
+		if d.invalid || d.pos >= len(d.input)+2 {
+			return 0, false
+		}
+
+		d.head = 0
+		d.tail = 0
+		d.step()
+	}
+
+	n := d.values[d.head]
+	d.head++
+
+	return n, true
+}
+
+// step decodes the values that end
+// in the current input byte.
+func (d *decoder) step() {
+	if d.pos >= len(d.input) && d.wpos < len(d.words) {
+		d.fill()
+	}
+
+	in := byte(0)
+	if d.pos < len(d.input) {
+		in = d.input[d.pos]
+	}
+	d.pos++
+
+	startWithOne := false
+	endWithOne := d.prevIn&0x80 != 0
+
+	rec := fdecTable[0][in]
+	if in&1 == 1 && rec.shift > 0 {
+		startWithOne = true
+		d.prevRec = fdecTable[1][d.prevIn]
+	}
+	d.prevIn = in
+
+	shift := int(d.prevRec.shift)
+	if shift > 0 {
+		d.fbuffer[d.nbuf] = d.prevRec.incomplete
+		d.nbuf++
+		if d.nbuf > maxCodeBytes {
+			if DebugLogger != nil {
+				debugf("code ending at byte %d is too long", d.pos-1)
+			}
+			d.invalid = true
+			return
+		}
+	}
+
+	dec := uint(0)
+	for _, num := range d.prevRec.numbers {
+		if shift == 0 {
+			dec = decodeBuffer(d.fbuffer[:d.nbuf], 8)
+		} else {
+			dec = decodeBuffer(d.fbuffer[:d.nbuf], shift)
+		}
+		d.push(dec)
+
+		shift = 0
+		d.fbuffer[0] = num
+		d.nbuf = 1
+	}
+
+	if startWithOne && endWithOne {
+		dec = decodeBuffer(d.fbuffer[:d.nbuf], 7)
+		d.nbuf = 0
+		d.push(dec)
+	}
+
+	d.prevRec = rec
+}
+
+// push adds dec to the decoded values
+// if it is not a terminating or padding
+// code.
+func (d *decoder) push(dec uint) {
+	const mask = ^(^uint(0) >> 1)
+
+	if dec > 1 && !d.invalid {
+		// Subtract 2 to cancel out
+		// what is added during encoding
+		dec -= 2
+		if dec&^mask > MaxValue {
+			if DebugLogger != nil {
+				debugf("value ending at byte %d is out of range", d.pos-1)
+			}
+			d.invalid = true
+			return
+		}
+
+		n := fromSignMagnitude(dec) >> d.flagBits
+		if d.dict != nil {
+			if n < 0 || n >= len(d.dict) {
+				if DebugLogger != nil {
+					debugf("value ending at byte %d is not in the dictionary", d.pos-1)
+				}
+				d.invalid = true
+				return
+			}
+			n = d.dict[n]
+		} else {
+			n += d.base
+		}
+
+		d.values[d.tail] = n
+		d.tail++
+	}
 }
 
 func decodeBuffer(fbuffer []byte, shift int) uint {
@@ -200,14 +543,99 @@ func lfibshift(n uint, shift int) uint {
 	return (fib[shift] * n) + (fib[shift-1] * uint(vf1[n]))
 }
 
-func byteSliceFromUint64Slice(bits []uint64) []byte {
-	sh := &reflect.SliceHeader{}
-	sh.Cap = cap(bits) * 8
-	sh.Len = len(bits) * 8
-	sh.Data = (uintptr)(unsafe.Pointer(&bits[0]))
-	bytes := *(*[]uint8)(unsafe.Pointer(sh))
+// byteTail returns the bytes of b starting from
+// the ith byte. This returns an empty slice instead
+// of panicking if i is beyond the end of b, which
+// happens if the trailing zero words of a bit array
+// are not included in its backing slice.
+func byteTail(b []byte, i int) []byte {
+	if i >= len(b) {
+		return nil
+	}
+
+	return b[i:]
+}
+
+// getBits returns n bits (n <= 64) from
+// the bit array words starting at index.
+// Bits beyond words are treated as zeros.
+func getBits(words []uint64, index, n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	aidx := index >> 6
+	bidx := uint(index & 63)
+
+	var res uint64
+	if aidx < len(words) {
+		res = words[aidx] >> bidx
+	}
+	if bidx > 0 && aidx+1 < len(words) {
+		res |= words[aidx+1] << (64 - bidx)
+	}
+	if n < 64 {
+		res &= (1 << uint(n)) - 1
+	}
+
+	return res
+}
+
+// nextStart returns the index of the first
+// encoded value that starts after index.
+// This returns -1 if there's none.
+func nextStart(words []uint64, index int) int {
+	index++
+	for aidx := index >> 6; aidx < len(words); aidx++ {
+		next := uint64(0)
+		if aidx+1 < len(words) {
+			next = words[aidx+1]
+		}
+
+		s := starts11_64(words[aidx], next)
+		if aidx == index>>6 {
+			s &= ^uint64(0) << uint(index&63)
+		}
+
+		if s != 0 {
+			return aidx<<6 + bit.Select(s, 1)
+		}
+	}
+
+	return -1
+}
+
+// countStarts returns the number of encoded
+// values in the first nbits of words.
+func countStarts(words []uint64, nbits int) int {
+	nwords := (nbits + 63) >> 6
+	if nwords > len(words) {
+		nwords = len(words)
+	} else if nwords < 0 {
+		nwords = 0
+	}
+
+	count := 0
+	for i, w := range words[:nwords] {
+		next := uint64(0)
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+		count += bit.PopCount(starts11_64(w, next))
+	}
+
+	return count
+}
+
+// addInt returns a+b and false
+// if the result overflows an int.
+func addInt(a, b int) (int, bool) {
+	c := a + b
+	if (c > a) != (b > 0) {
+		return c, false
+	}
 
-	return bytes
+	return c, true
 }
 
 func toSignMagnitude(v int) uint {
@@ -1,6 +1,8 @@
 package fibvec
 
 import (
+	"bytes"
+	"encoding/gob"
 	"math/rand"
 	"testing"
 
@@ -33,12 +35,244 @@ func TestFibEncDec(t *testing.T) {
 			break
 		}
 	}
+
+	// Appending keeps the existing values
+	result = fibdecodeAppend([]int{-1}, bytes, 10)
+	assert.Len(t, result, 11)
+	assert.Equal(t, -1, result[0])
+	assert.EqualValues(t, values[9], result[10])
+}
+
+func TestFibDecodeOne(t *testing.T) {
+	values := []uint{0, 1, 2, 3, 100, MaxValue, toSignMagnitude(-1), toSignMagnitude(MinValue)}
+	for i := 0; i < 1e4; i++ {
+		values = append(values, uint(rand.Int63()))
+	}
+
+	for _, v := range values {
+		// Start at a random bit offset
+		// with the bits before it zeroed
+		skip := rand.Intn(8)
+		array := bit.NewArray(0)
+		array.Add(0, skip)
+
+		fc, lfc := fibencode(v)
+		for _, f := range fc[:len(fc)-1] {
+			array.Add(f, 64)
+			lfc -= 64
+		}
+		array.Add(fc[len(fc)-1], lfc)
+		array.Add(0x3, 3)
+
+		bytes := byteSliceFromUint64Slice(array.Bits())
+		n, ok := fibdecodeOne(bytes)
+		assert.True(t, ok)
+		if !assert.Equal(t, fibdecode(bytes, 1)[0], fromSignMagnitude(n)) {
+			break
+		}
+		assert.Equal(t, v, n)
+	}
+
+	// Codes that are too long
+	_, ok := fibdecodeOne([]byte{0x3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	assert.False(t, ok)
+	_, ok = fibdecodeOne([]byte{0})
+	assert.False(t, ok)
+}
+
+func TestEncodeDecodeFib(t *testing.T) {
+	values := []uint64{0, 1, 2, 3, 100, MaxValue}
+	for i := 0; i < 1e4; i++ {
+		values = append(values, uint64(rand.Int63n(MaxValue)))
+	}
+
+	array := bit.NewArray(0)
+	for _, n := range values {
+		b := EncodeFib(n)
+		assert.Len(t, b, (EncodedLen(int(n))+3+7)/8)
+		if !assert.Equal(t, []uint64{n}, DecodeFib(b, 2)) {
+			break
+		}
+
+		fc, lfc := fibencode(uint(n))
+		for _, f := range fc[:len(fc)-1] {
+			array.Add(f, 64)
+			lfc -= 64
+		}
+		array.Add(fc[len(fc)-1], lfc)
+	}
+	array.Add(0x3, 3)
+
+	// Consecutive codes
+	bytes := byteSliceFromUint64Slice(array.Bits())
+	assert.Equal(t, values, DecodeFib(bytes, len(values)))
+	assert.Equal(t, values[:10], DecodeFib(bytes, 10))
+
+	assert.Equal(t, []byte{0x33}, EncodeFib(1))
+	assert.Equal(t, []uint64{}, DecodeFib(nil, 1))
+	assert.Equal(t, []uint64{}, DecodeFib(EncodeFib(5), 0))
+	assert.Panics(t, func() { EncodeFib(MaxValue + 1) })
+	assert.Panics(t, func() { DecodeFib(EncodeFib(5), -1) })
+}
+
+func TestDecodeTrimmedInput(t *testing.T) {
+	vec := buildDeterministic(100, 1)
+	values := vec.ToSlice()
+
+	// Trim the trailing zero bytes
+	// after the terminating bits
+	bytes := byteSliceFromUint64Slice(vec.bits.Bits())
+	n := (vec.bits.Len() + 7) >> 3
+	for n > 0 && bytes[n-1] == 0 {
+		n--
+	}
+	trimmed := append([]byte{}, bytes[:n]...)
+
+	assert.Equal(t, values, fibdecode(trimmed, len(values)))
+	last := vec.BitOffset(len(values) - 1)
+
+	d := decoder{}
+	d.reset(byteTail(trimmed, last>>3), uint(last&7))
+	v, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, values[len(values)-1], v)
+	_, ok = d.next()
+	assert.False(t, ok)
+
+	// Beyond the end is all zeros
+	assert.Nil(t, byteTail(trimmed, len(trimmed)))
+	d.reset(byteTail(trimmed, len(trimmed)+8), 3)
+	_, ok = d.next()
+	assert.False(t, ok)
+	_, ok = fibdecodeOne(nil)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(0), getBits(vec.bits.Bits(), vec.bits.Len()+128, 64))
+}
+
+func TestTrimmedWords(t *testing.T) {
+	// Add values until the last word only
+	// contains the zero bit of the terminator
+	r := rand.New(rand.NewSource(1))
+	vec := NewVector()
+	for i := 0; i < 5e3 || vec.bits.Len()&63 != 1; i++ {
+		vec.Add(r.Intn(1e3))
+	}
+	values := vec.ToSlice()
+
+	nwords := (vec.bits.Len() + 63) >> 6
+	words := vec.bits.Bits()[:nwords]
+	assert.Equal(t, uint64(0), words[nwords-1])
+
+	// Decode a bit array without the zero word
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	assert.Nil(t, enc.Encode(words[:nwords-1]))
+	assert.Nil(t, enc.Encode(vec.bits.Len()))
+	short := bit.NewArray(0)
+	assert.Nil(t, short.GobDecode(buf.Bytes()))
+
+	tvec := vec.Clone()
+	tvec.bits = short
+	assert.True(t, len(tvec.bits.Bits())<<6 < tvec.bits.Len())
+
+	for i, v := range values {
+		if !assert.Equal(t, v, tvec.Get(i)) {
+			break
+		}
+	}
+	assert.Equal(t, values, tvec.ToSlice())
+	assert.Equal(t, values[len(values)-10:], tvec.GetValues(len(values)-10, len(values)))
+	assert.Equal(t, values[1234:2345], tvec.GetValues(1234, 2345))
+
+	for i := 1; i <= len(values)+1; i++ {
+		if !assert.Equal(t, vec.select11(i), tvec.select11(i)) {
+			break
+		}
+	}
+	for k := 0; k <= vec.bits.Len(); k++ {
+		if !assert.Equal(t, vec.rank11(k), tvec.rank11(k)) {
+			break
+		}
+	}
+
+	// Rebuilding the samples from
+	// the short words gives the same
+	tvec.ranks, tvec.indices = sampleArray{}, sampleArray{}
+	tvec.buildIndex()
+	assert.Equal(t, vec.ranks.ints(), tvec.ranks.ints())
+	assert.Equal(t, vec.indices.ints(), tvec.indices.ints())
+
+	// The short words also pass validation
+	data, err := tvec.GobEncode()
+	assert.Nil(t, err)
+	assert.Nil(t, ValidateSerialized(data))
+	nvec := NewVector()
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.Equal(t, values, nvec.ToSlice())
+	assert.Nil(t, nvec.ValidateCodes())
+
+	data, err = tvec.MarshalBinary()
+	assert.Nil(t, err)
+	nvec = NewVector()
+	assert.Nil(t, nvec.UnmarshalBinary(data))
+	assert.Equal(t, values, nvec.ToSlice())
+	assert.True(t, nvec.Equal(tvec))
+}
+
+func TestByteSliceFromUint64Slice(t *testing.T) {
+	assert.Empty(t, byteSliceFromUint64Slice(nil))
+	assert.Empty(t, byteSliceFromUint64Slice([]uint64{}))
+	assert.Equal(t, []byte{1, 2, 0, 0, 0, 0, 0, 0x80}, byteSliceFromUint64Slice([]uint64{1<<63 | 0x0201}))
+
+	// Empty and single value vectors
+	vec := NewVector()
+	assert.Equal(t, []int{}, vec.ToSlice())
+	assert.Equal(t, []int{}, fibdecode(byteSliceFromUint64Slice(vec.bits.Bits()), 1))
+
+	vec.Add(-7)
+	assert.Equal(t, -7, vec.Get(0))
+	assert.Equal(t, []int{-7}, vec.GetValues(0, 1))
+	assert.Equal(t, []int{-7}, fibdecode(byteSliceFromUint64Slice(vec.bits.Bits()), 1))
+}
+
+func TestEncodedLen(t *testing.T) {
+	samples := []int{0, 1, 2, 3, 10, 100, 1e3, 1e6, 1e9, 1e12, 1e15, MaxValue}
+	for _, n := range samples {
+		_, lfc := fibencode(toSignMagnitude(n))
+		assert.Equal(t, lfc, EncodedLen(n))
+		assert.Equal(t, float64(lfc)/8, BytesForValue(uint64(n)))
+
+		_, lfc = fibencode(toSignMagnitude(-n))
+		assert.Equal(t, lfc, EncodedLen(-n))
+	}
+
+	for i := 0; i < 1e4; i++ {
+		n := int(rand.Int63n(MaxValue)) - MaxValue/2
+		_, lfc := fibencode(toSignMagnitude(n))
+		if !assert.Equal(t, lfc, EncodedLen(n)) {
+			break
+		}
+	}
+
+	prev := 0.0
+	for n := uint64(0); n < MaxValue/3; n = n*3 + 1 {
+		cost := BytesForValue(n)
+		if !assert.True(t, cost >= prev) {
+			break
+		}
+		prev = cost
+	}
+
+	assert.Panics(t, func() { BytesForValue(MaxValue + 1) })
+	assert.Panics(t, func() { EncodedLen(MinValue - 1) })
 }
 
 func BenchmarkFibEnc(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+
 	val := make([]uint, b.N)
 	for i := range val {
-		val[i] = uint(rand.Int63())
+		val[i] = uint(r.Int63())
 	}
 
 	b.ResetTimer()
@@ -48,9 +282,11 @@ func BenchmarkFibEnc(b *testing.B) {
 }
 
 func BenchmarkFibDec(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+
 	enc := make([][]byte, 1e5)
 	for i := range enc {
-		v := uint(rand.Int63())
+		v := uint(r.Int63())
 		fc, lfc := fibencode(v)
 
 		array := bit.NewArray(0)
@@ -66,7 +302,7 @@ func BenchmarkFibDec(b *testing.B) {
 
 	idx := make([]int, b.N)
 	for i := range idx {
-		idx[i] = rand.Intn(len(enc))
+		idx[i] = r.Intn(len(enc))
 	}
 
 	b.ResetTimer()
@@ -74,3 +310,33 @@ func BenchmarkFibDec(b *testing.B) {
 		fibdecode(enc[idx[i]], 1)
 	}
 }
+
+func BenchmarkFibDecOne(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+
+	enc := make([][]byte, 1e5)
+	for i := range enc {
+		v := uint(r.Int63())
+		fc, lfc := fibencode(v)
+
+		array := bit.NewArray(0)
+		for _, f := range fc[:len(fc)-1] {
+			array.Add(f, 64)
+			lfc -= 64
+		}
+		array.Add(fc[len(fc)-1], lfc)
+		array.Add(0x3, 16)
+
+		enc[i] = byteSliceFromUint64Slice(array.Bits())
+	}
+
+	idx := make([]int, b.N)
+	for i := range idx {
+		idx[i] = r.Intn(len(enc))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fibdecodeOne(enc[idx[i]])
+	}
+}
@@ -0,0 +1,187 @@
+package fibvec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/robskie/bit"
+)
+
+// Errors returned by ValidateSerialized.
+var (
+	ErrMalformed        = errors.New("fibvec: malformed serialized vector")
+	ErrNoTerminator     = errors.New("fibvec: missing terminating bits")
+	ErrConsecutiveOnes  = errors.New("fibvec: too many consecutive ones")
+	ErrPopcountMismatch = errors.New("fibvec: popcount does not match the number of values")
+	ErrRankMismatch     = errors.New("fibvec: rank samples do not match the bit array")
+	ErrIndexMismatch    = errors.New("fibvec: select samples do not match the bit array")
+	ErrInvalidCode      = errors.New("fibvec: invalid encoded value")
+)
+
+// ValidateSerialized checks the integrity of a vector serialized
+// using GobEncode without decoding its values. This checks that
+// the bit array contains properly separated codes and that they
+// agree with the stored length and the rank and select samples
+// unless the samples are omitted.
+func ValidateSerialized(data []byte) error {
+	vec := &Vector{}
+	if err := vec.decodeFields(data); err != nil {
+		return fmt.Errorf("%w (%v)", ErrMalformed, err)
+	}
+
+	return validate(
+		vec.bits,
+		vec.ranks.ints(),
+		vec.indices.ints(),
+		vec.popcount,
+		vec.length,
+		vec.compact,
+		vec.sr,
+		vec.ss,
+	)
+}
+
+// check verifies that a decoded vector is consistent so that
+// corrupt input is reported when it is decoded instead of making
+// later calls index out of range. If the samples are omitted,
+// only the bit array is checked so that the samples are still
+// built lazily. The values are not decoded, see ValidateCodes.
+func (v *Vector) check() error {
+	return validate(
+		v.bits,
+		v.ranks.ints(),
+		v.indices.ints(),
+		v.popcount,
+		v.length,
+		v.compact,
+		v.sr,
+		v.ss,
+	)
+}
+
+// ValidateCodes decodes every value to find codes that are out of
+// range or that are not in the dictionary, which would make Get
+// panic. Decoding only checks the structure of the vector, so this
+// can be used after decoding a vector from untrusted input.
+func (v *Vector) ValidateCodes() error {
+	if !v.initialized {
+		return nil
+	}
+
+	// The first value always starts at bit 0 so
+	// this doesn't need to build omitted samples
+	d := decoder{base: v.base, dict: v.dict, flagBits: v.flagBits}
+	d.resetWords(v.bits.Bits(), 0)
+
+	count := 0
+	for _, ok := d.next(); ok; _, ok = d.next() {
+		count++
+	}
+	if count != v.length {
+		return fmt.Errorf("%w (at index %d)", ErrInvalidCode, count)
+	}
+
+	return nil
+}
+
+// validate checks whether the given
+// vector components are consistent
+// given the sampling block sizes. The
+// samples are not checked if both are
+// empty, ie., if they are omitted.
+func validate(
+	bits *bit.Array,
+	ranks []int,
+	indices []int,
+	popcount int,
+	length int,
+	compact bool,
+	sr int,
+	ss int) error {
+
+	nbits := bits.Len()
+	words := bits.Bits()
+	// The trailing zero words may be missing
+	// but not the ones of the terminating bits
+	indexed := len(ranks) > 0
+	if nbits < 3 || len(words)<<6 < nbits-1 || indexed != (len(indices) > 0) {
+		return ErrMalformed
+	} else if len(ranks) > nbits/sr+1 {
+		return fmt.Errorf("%w (%d extra blocks)", ErrRankMismatch, len(ranks)-nbits/sr-1)
+	} else if len(indices) > length/ss+1 {
+		return fmt.Errorf("%w (%d extra samples)", ErrIndexMismatch, len(indices)-length/ss-1)
+	}
+
+	get := func(i int) uint64 {
+		return getBits(words, i, 1)
+	}
+
+	if get(nbits-3) != 1 || get(nbits-2) != 1 || get(nbits-1) != 0 {
+		return ErrNoTerminator
+	}
+
+	// Only padding bits, which are placed at
+	// array boundaries, can make a run of ones
+	// longer than 3.
+	run := 0
+	for i := 0; i <= nbits; i++ {
+		if i < nbits && get(i) == 1 {
+			run++
+			continue
+		}
+
+		padded := (i-1)>>6 != (i-run)>>6
+		if run > 5 || (run > 3 && (compact || !padded)) {
+			return fmt.Errorf("%w (at bit %d)", ErrConsecutiveOnes, i-run)
+		}
+		run = 0
+	}
+
+	// Count the beginning of every encoded value while
+	// checking the rank and select samples along the way
+	nwords := (nbits + 63) >> 6
+	if nwords > len(words) {
+		nwords = len(words)
+	}
+
+	count := 0
+	for i, w := range words[:nwords] {
+		if i<<6%sr == 0 {
+			r := i << 6 / sr
+			if r < len(ranks) && ranks[r] != count {
+				return fmt.Errorf("%w (at block %d)", ErrRankMismatch, r)
+			}
+		}
+
+		next := uint64(0)
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+
+		s := starts11_64(w, next)
+		for s != 0 {
+			idx := i<<6 + bit.Select(s, 1)
+			s &= s - 1
+
+			if indexed && count < length && count%ss == 0 {
+				j := count / ss
+				if j >= len(indices) || indices[j]/sr != idx/sr {
+					return fmt.Errorf("%w (at sample %d)", ErrIndexMismatch, j)
+				}
+			}
+
+			if indexed && count < length && idx >= len(ranks)*sr {
+				return fmt.Errorf("%w (missing block %d)", ErrRankMismatch, len(ranks))
+			}
+			count++
+		}
+	}
+
+	// The terminating bits are
+	// also counted as a value
+	if popcount != length || count != length+1 {
+		return ErrPopcountMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package fibvec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"testing"
+
+	"github.com/robskie/bit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSerialized(t *testing.T) {
+	vec := NewVector()
+	data, _ := vec.GobEncode()
+	assert.Nil(t, ValidateSerialized(data))
+
+	for i := 0; i < 1e5; i++ {
+		vec.Add(rand.Intn(MaxValue))
+	}
+	data, _ = vec.GobEncode()
+	assert.Nil(t, ValidateSerialized(data))
+
+	vec.Freeze()
+	data, _ = vec.GobEncode()
+	assert.Nil(t, ValidateSerialized(data))
+
+	assert.ErrorIs(t, ValidateSerialized(data[:len(data)/2]), ErrMalformed)
+	clean := data
+
+	// Insert a run of ones
+	nvec := NewVector()
+	assert.Nil(t, nvec.GobDecode(clean))
+	nvec.bits.Bits()[100] |= 0xF0
+	data, _ = nvec.GobEncode()
+	assert.ErrorIs(t, ValidateSerialized(data), ErrConsecutiveOnes)
+
+	// Change the rank samples
+	nvec = NewVector()
+	assert.Nil(t, nvec.GobDecode(clean))
+	nvec.ranks.set(10, nvec.ranks.at(10)+1)
+	data, _ = nvec.GobEncode()
+	assert.ErrorIs(t, ValidateSerialized(data), ErrRankMismatch)
+
+	// Change the length
+	nvec.ranks.set(10, nvec.ranks.at(10)-1)
+	nvec.length++
+	data, _ = nvec.GobEncode()
+	assert.ErrorIs(t, ValidateSerialized(data), ErrPopcountMismatch)
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	frozen := buildDeterministic(2e3, 3)
+	frozen.Freeze()
+	flagged := NewVectorWithOptions(WithFlags(2))
+	implicit := NewVectorWithOptions(WithImplicitLength(), WithoutIndex())
+	for i := 0; i < 2e3; i++ {
+		flagged.AddWithFlag(rand.Intn(1e6), rand.Intn(4))
+		implicit.Add(rand.Intn(1e3))
+	}
+
+	vecs := []*Vector{
+		buildDeterministic(2e3, 1),
+		frozen,
+		flagged,
+		implicit,
+		NewVectorFromSlice(skewedValues(2e3)).OptimizeByFrequency(),
+	}
+
+	// Either decoding or validating the codes
+	// fails, or the decoded values can be read
+	// without panicking
+	decode := func(nvec *Vector, err error) bool {
+		if err != nil {
+			return assert.Equal(t, []int{1, 2, 3}, nvec.ToSlice())
+		} else if nvec.ValidateCodes() != nil {
+			return true
+		}
+
+		return assert.NotPanics(t, func() {
+			nvec.ToSlice()
+			nvec.Get(nvec.Len() / 2)
+			nvec.GetValues(0, nvec.Len())
+		})
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, vec := range vecs {
+		gobbed, err := vec.GobEncode()
+		assert.Nil(t, err)
+		binary, err := vec.MarshalBinary()
+		assert.Nil(t, err)
+
+		for i := 0; i < 200; i++ {
+			bad := append([]byte{}, gobbed...)
+			bad[r.Intn(len(bad))] ^= byte(1 << uint(r.Intn(8)))
+			nvec := NewVectorFromSlice([]int{1, 2, 3})
+			if !decode(nvec, nvec.GobDecode(bad)) {
+				return
+			}
+
+			bad = append([]byte{}, binary...)
+			bad[r.Intn(len(bad))] ^= byte(1 << uint(r.Intn(8)))
+			nvec = NewVectorFromSlice([]int{1, 2, 3})
+			if !decode(nvec, nvec.UnmarshalBinary(bad)) {
+				return
+			}
+		}
+	}
+
+	// A negative number of bits
+	// with an implicit length
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	assert.Nil(t, enc.Encode(implicit.bits.Bits()))
+	assert.Nil(t, enc.Encode(-419))
+	negative := implicit.Clone()
+	negative.bits = bit.NewArray(0)
+	assert.Nil(t, negative.bits.GobDecode(buf.Bytes()))
+	data, err := negative.GobEncode()
+	assert.Nil(t, err)
+	assert.NotNil(t, (&Vector{}).GobDecode(data))
+
+	// Corrupt codes are not found by decoding
+	// if the samples agree but by ValidateCodes
+	vec := NewVectorFromSlice([]int{1, 2, 3}).OptimizeByFrequency()
+	vec.Set(1, 1)
+	vec.dict = vec.dict[:1]
+	data, err = vec.MarshalBinary()
+	assert.Nil(t, err)
+	nvec := &Vector{}
+	assert.Nil(t, nvec.UnmarshalBinary(data))
+	assert.ErrorIs(t, nvec.ValidateCodes(), ErrInvalidCode)
+	nvec = &Vector{}
+	_, err = nvec.ReadFrom(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.ErrorIs(t, nvec.ValidateCodes(), ErrInvalidCode)
+	assert.Panics(t, func() { nvec.Get(2) })
+
+	assert.Nil(t, (&Vector{}).ValidateCodes())
+	assert.Nil(t, buildDeterministic(1e3, 1).ValidateCodes())
+}
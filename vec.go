@@ -6,13 +6,20 @@ package fibvec
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
-	"unsafe"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
 
 	"github.com/robskie/bit"
 )
 
+// These are the default sampling block sizes which
+// can be changed using WithRankBlock and WithSelectBlock.
 const (
 	// These variables affects the size and
 	// speed of the vector. Lower values means
@@ -29,193 +36,1449 @@ const (
 	ss = 640
 )
 
+// FormatVersion is the version of the serialized format
+// written by GobEncode. Version 1 is the original format
+// which doesn't include the version in the stream. This
+// doesn't apply to the binary format written by WriteTo
+// which has its own version.
+const FormatVersion = 3
+
+// Mode flags included in the gob stream. Decoding
+// fails if a flag that is not listed here is set.
+const (
+	flagCompact uint64 = 1 << iota
+	flagImplicitLength
+	flagImplicitIndex
+	flagDictionary
+	flagElementFlags
+	flagSampling
+
+	knownFlags = flagCompact | flagImplicitLength | flagImplicitIndex |
+		flagDictionary | flagElementFlags | flagSampling
+)
+
 // Vector represents a container for unsigned integers.
 type Vector struct {
 	bits *bit.Array
 
 	// ranks[i] is the number of 11s
 	// from 0 to index (i*sr)-1
-	ranks []int
+	ranks sampleArray
+
+	// indices[i] points to the
+	// beginning of the uint64 (LSB)
+	// that contains the (i*ss)+1th
+	// pair of bits.
+	indices sampleArray
+
+	popcount int
+
+	length      int
+	initialized bool
+
+	// compact is true if the values
+	// are packed without padding bits.
+	compact bool
+
+	// base is subtracted from the
+	// values before they are encoded.
+	base int
+
+	// implicitLength is true if the length
+	// is not included in the gob stream.
+	implicitLength bool
+
+	// implicitIndex is true if the rank and
+	// select samples are not included in the
+	// gob stream. These are then rebuilt from
+	// the bit array when first needed.
+	implicitIndex bool
+
+	// dict maps the encoded values to the
+	// actual values if it is not nil and
+	// dictIndex is its lazily built inverse.
+	dict      []int
+	dictIndex map[int]int
+
+	// flagBits is the number of low bits
+	// of each encoded value that contain
+	// the flag of the value.
+	flagBits uint
+
+	// sr and ss are the rank and select
+	// sampling block sizes of the vector.
+	sr int
+	ss int
+
+	// hash caches the content hash and
+	// hashed is true if it is up to date.
+	hash   uint64
+	hashed bool
+
+	// sum is the sum of all the values and
+	// sumOK is false if it overflows. These
+	// are only valid if summed is true.
+	sum    int
+	sumOK  bool
+	summed bool
+}
+
+// Option configures a vector.
+type Option func(*Vector)
+
+// WithBase sets the minimum value that can be stored
+// in the vector. Each value is stored as its difference
+// from base which shrinks the encoded values if they
+// are all far from zero. A zero base means that there
+// is no minimum value.
+func WithBase(base int) Option {
+	return func(v *Vector) {
+		v.base = base
+	}
+}
+
+// Initialize vector
+func (v *Vector) init() {
+	v.initWithCapacity(0, 0)
+}
+
+// initWithCapacity initializes the vector with room
+// for nbits bits in the bit array and the rank and
+// select samples of count values spanning them. This
+// does nothing if the vector is already initialized.
+// Otherwise, any length set without the bit array is
+// discarded so that the vector starts out empty.
+func (v *Vector) initWithCapacity(nbits, count int) {
+	if v.initialized {
+		return
+	}
+
+	if v.sr == 0 {
+		v.sr = sr
+	}
+	if v.ss == 0 {
+		v.ss = ss
+	}
+
+	v.bits = bit.NewArray(nbits)
+	v.ranks = makeSamples(1, nbits/v.sr+1)
+	v.indices = makeSamples(1, count/v.ss+1)
+
+	// Add terminating bits
+	v.bits.Add(0x3, 3)
+
+	v.length = 0
+	v.popcount = 0
+
+	v.sum = 0
+	v.sumOK = true
+	v.summed = true
+	v.hashed = false
+
+	v.initialized = true
+}
+
+// NewVector creates a new vector.
+func NewVector() *Vector {
+	vec := &Vector{}
+	vec.init()
+	return vec
+}
+
+// WithImplicitLength omits the length of the vector
+// from the gob stream. The length is then recovered by
+// counting the encoded values when the vector is decoded.
+func WithImplicitLength() Option {
+	return func(v *Vector) {
+		v.implicitLength = true
+	}
+}
+
+// WithoutIndex omits the rank and select samples from the
+// gob stream which makes it smaller and faster to decode. The
+// samples are then rebuilt from the bit array the first time
+// they are needed, eg., on the first Get. Until then, reading
+// from the decoded vector is not safe for concurrent use.
+func WithoutIndex() Option {
+	return func(v *Vector) {
+		v.implicitIndex = true
+	}
+}
+
+// WithRankBlock sets the number of bits in each rank
+// sampling block which defaults to 512. Smaller blocks make
+// Gets faster but take more space. This panics if bits is
+// not a positive multiple of 64.
+func WithRankBlock(bits int) Option {
+	if !validSampling(bits, ss) {
+		panic("fibvec: rank block size must be a positive multiple of 64")
+	}
+
+	return func(v *Vector) {
+		v.sr = bits
+	}
+}
+
+// WithSelectBlock sets the number of values in each select
+// sampling block which defaults to 640. Like WithRankBlock,
+// smaller blocks make Gets faster but take more space. This
+// panics if ones is not positive.
+func WithSelectBlock(ones int) Option {
+	if !validSampling(sr, ones) {
+		panic("fibvec: select block size must be positive")
+	}
+
+	return func(v *Vector) {
+		v.ss = ones
+	}
+}
+
+// validSampling returns true if the rank and select
+// sampling block sizes can be used by a vector.
+func validSampling(rankBits, selectOnes int) bool {
+	return rankBits > 0 && rankBits%64 == 0 && selectOnes > 0
+}
+
+// NewVectorWithOptions creates a new
+// vector configured with the given options.
+func NewVectorWithOptions(opts ...Option) *Vector {
+	vec := NewVector()
+	for _, opt := range opts {
+		opt(vec)
+	}
+
+	return vec
+}
+
+// NewVectorFromSlice creates a new vector containing the
+// given integers. The result is the same as adding them one
+// by one but the bit array is allocated only once.
+func NewVectorFromSlice(ns []int) *Vector {
+	// Estimate the size of the bit array
+	// including the padding and terminating
+	// bits. Invalid values are left to AddBatch.
+	nbits := 3
+	for _, n := range ns {
+		if n <= MaxValue && n >= MinValue {
+			nbits += EncodedLen(n)
+		}
+	}
+	nbits += nbits / 32
+
+	vec := &Vector{}
+	vec.initWithCapacity(nbits, len(ns))
+	vec.AddBatch(ns)
+	return vec
+}
+
+// Grow reserves room for nbits more bits so that adding
+// values whose encoded lengths sum up to nbits doesn't grow
+// the bit array nor the rank and select samples. EncodedLen
+// can be used to get the number of bits of each value. Since
+// the number of values is not known, the select samples are
+// reserved for the largest number of values that fits.
+func (v *Vector) Grow(nbits int) {
+	if nbits < 0 {
+		panic("fibvec: number of bits must not be negative")
+	}
+
+	// Include the padding bits
+	nbits += nbits / 32
+
+	if !v.initialized {
+		v.initWithCapacity(nbits+3, nbits/3)
+		return
+	} else if !v.indexBuilt() {
+		v.buildIndex()
+	}
+
+	vlen := v.bits.Len()
+	v.bits = copyBits(v.bits, vlen, nbits)
+
+	v.ranks.reserve((vlen+nbits)/v.sr + 1)
+	v.indices.reserve((v.length+nbits/3)/v.ss + 1)
+}
+
+// newVectorLike creates an empty vector
+// that has the same settings as v.
+func newVectorLike(v *Vector) *Vector {
+	vec := &Vector{
+		compact:        v.compact,
+		base:           v.base,
+		implicitLength: v.implicitLength,
+		implicitIndex:  v.implicitIndex,
+		dict:           v.dict,
+		flagBits:       v.flagBits,
+		sr:             v.sr,
+		ss:             v.ss,
+	}
+	vec.init()
+	return vec
+}
+
+// newPlainVectorLike creates an empty vector with the same
+// padding and sampling options as v but without its base,
+// dictionary, and flags so that it can hold any value.
+func newPlainVectorLike(v *Vector) *Vector {
+	vec := &Vector{
+		compact:        v.compact,
+		implicitLength: v.implicitLength,
+		implicitIndex:  v.implicitIndex,
+		sr:             v.sr,
+		ss:             v.ss,
+	}
+	vec.init()
+	return vec
+}
+
+// Add adds an integer to the vector.
+func (v *Vector) Add(n int) {
+	v.add(n, 0)
+}
+
+// add adds n with the given flag.
+func (v *Vector) add(n, flag int) {
+	if n > MaxValue || n < MinValue {
+		panic("fibvec: input is not in the range of encodable values")
+	} else if v.base != 0 && (n < v.base || uint(n-v.base) > MaxValue) {
+		panic("fibvec: input is not in the range of the vector base")
+	} else if v.dict != nil && !v.inDict(n) {
+		panic("fibvec: input is not in the dictionary")
+	} else if v.flagBits != 0 && !v.fitsFlags(n) {
+		panic("fibvec: input is not in the range of flagged values")
+	} else if !v.initialized {
+		v.init()
+	}
+
+	// Convert to sign-magnitude representation
+	// so that "small" negative numbers such as
+	// -1, -2, -3... can be encoded
+	nn := toSignMagnitude(v.offset(n) | flag)
+
+	v.addCode(fibencode(nn))
+
+	if v.summed && v.sumOK {
+		v.sum, v.sumOK = addInt(v.sum, n)
+	}
+}
+
+// AddUint64 adds an unsigned integer to the vector. Since
+// MaxValue fits in an int, this is the same as Add except
+// that it panics instead of wrapping if n is larger than
+// MaxValue.
+func (v *Vector) AddUint64(n uint64) {
+	if n > MaxValue {
+		panic("fibvec: input is not in the range of encodable values")
+	}
+
+	v.Add(int(n))
+}
+
+// AddBatch adds the given integers to the vector. The result
+// is the same as calling Add on each of them but the terminating
+// bits are only moved once and the rank and select samples are
+// updated after all the values are encoded. This panics without
+// adding any value if one of them cannot be added.
+func (v *Vector) AddBatch(ns []int) {
+	for _, n := range ns {
+		if n > MaxValue || n < MinValue {
+			panic("fibvec: input is not in the range of encodable values")
+		} else if v.base != 0 && (n < v.base || uint(n-v.base) > MaxValue) {
+			panic("fibvec: input is not in the range of the vector base")
+		} else if v.dict != nil && !v.inDict(n) {
+			panic("fibvec: input is not in the dictionary")
+		} else if v.flagBits != 0 && !v.fitsFlags(n) {
+			panic("fibvec: input is not in the range of flagged values")
+		}
+	}
+
+	if len(ns) == 0 {
+		return
+	} else if !v.initialized {
+		v.init()
+	} else if !v.indexBuilt() {
+		v.buildIndex()
+	}
+
+	// Remove the terminating bits by
+	// overwriting them with the first code
+	starts := make([]int, len(ns))
+	for i, n := range ns {
+		idx := v.bits.Len()
+		if i == 0 {
+			idx -= 3
+		}
+		starts[i] = idx
+
+		fc, lfc := fibencode(toSignMagnitude(v.offset(n)))
+		for j, f := range fc {
+			size := 64
+			if j == len(fc)-1 {
+				size = lfc - j<<6
+			}
+
+			if i == 0 && j == 0 {
+				v.bits.Insert(idx, f, size)
+			} else {
+				v.bits.Add(f, size)
+			}
+		}
+
+		if !v.compact && (v.bits.Len()-1)&63 == 62 {
+			v.bits.Add(0x3, 2)
+		}
+
+		if v.summed && v.sumOK {
+			v.sum, v.sumOK = addInt(v.sum, n)
+		}
+	}
+	vlen := v.bits.Len()
+
+	// Update the rank and select samples
+	j := 0
+	for v.ranks.length()*v.sr < vlen {
+		boundary := v.ranks.length() * v.sr
+		for j < len(starts) && starts[j] < boundary {
+			j++
+		}
+		v.ranks.add(v.popcount + j)
+	}
+
+	for i, idx := range starts {
+		if c := v.popcount + i; c > 0 && c%v.ss == 0 {
+			v.indices.add(idx ^ 0x3F)
+		}
+	}
+
+	v.popcount += len(ns)
+	v.length += len(ns)
+
+	v.bits.Add(0x3, 3)
+	v.hashed = false
+}
+
+// AddRawCode adds an already encoded value to the vector. code
+// must be in the same format as the one produced when adding a
+// value, ie., a 1 followed by the fibonacci code of the value in
+// sign-magnitude representation plus 2 with its most significant
+// digit first, stored starting from the least significant bit of
+// code[0]. bits is the length of the code. If the vector has a
+// base, the encoded value must already have the base subtracted.
+// If it has a dictionary, the encoded value must be an index to
+// the dictionary. Flags are included in the encoded value.
+func (v *Vector) AddRawCode(code []uint64, bits int) {
+	if bits < 3 || bits > maxCodeBits || len(code) != (bits+63)>>6 {
+		panic("fibvec: invalid code length")
+	}
+
+	n, ok := decodeCode(code, bits)
+	k := n >> v.flagBits
+	if !ok {
+		panic("fibvec: invalid fibonacci code")
+	} else if v.dict != nil && (k < 0 || k >= len(v.dict)) {
+		panic("fibvec: code is not in the dictionary")
+	} else if v.dict == nil && v.base != 0 &&
+		(k < 0 || (v.base > 0 && k > MaxValue-v.base)) {
+		panic("fibvec: code is not in the range of the vector base")
+	} else if !v.initialized {
+		v.init()
+	}
+
+	v.addCode(code, bits)
+	v.summed = false
+}
+
+// decodeCode returns the value encoded in code and true
+// if code is a properly formed code of an encodable value.
+func decodeCode(code []uint64, bits int) (int, bool) {
+	// The code must start with 110
+	if code[0]&0x7 != 0x3 {
+		return 0, false
+	}
+
+	// and must not contain other 11s
+	// nor bits beyond its length
+	for i, c := range code {
+		n := 64
+		if i == len(code)-1 {
+			n = bits - (i << 6)
+		}
+
+		if n < 64 && c>>uint(n) != 0 {
+			return 0, false
+		}
+
+		c &= c >> 1
+		if i == 0 {
+			c &= ^uint64(1)
+		}
+		if c != 0 {
+			return 0, false
+		}
+		if i > 0 && code[i-1]>>63 == 1 && code[i]&1 == 1 {
+			return 0, false
+		}
+	}
+
+	// Decode it to check that the
+	// value is not larger than MaxValue
+	array := bit.NewArray(bits + 3)
+	for i, c := range code {
+		n := 64
+		if i == len(code)-1 {
+			n = bits - (i << 6)
+		}
+		array.Add(c, n)
+	}
+	array.Add(0x3, 3)
+
+	d := decoder{}
+	d.reset(byteSliceFromUint64Slice(array.Bits()), 0)
+
+	return d.next()
+}
+
+// Extend appends all the values of other to v. If both vectors
+// have the same base and flag bits and no dictionary, the encoded
+// values are copied directly without decoding them along with the
+// padding bits needed at their new positions.
+func (v *Vector) Extend(other *Vector) {
+	v.AppendRange(other, 0, other.length)
+}
+
+// Append is an alias of Extend.
+func (v *Vector) Append(other *Vector) {
+	v.Extend(other)
+}
+
+// AppendRange appends the values of src from start to end-1
+// to v. If both vectors have the same base and no dictionary,
+// the encoded values are copied directly without decoding them.
+func (v *Vector) AppendRange(src *Vector, start, end int) {
+	if end < start {
+		panic("fibvec: end must not be less than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > src.length {
+		panic("fibvec: index out of bounds")
+	} else if end == start {
+		return
+	}
+
+	if src == v || src.base != v.base || src.dict != nil || v.dict != nil ||
+		src.flagBits != v.flagBits {
+		for _, n := range src.GetValues(start, end) {
+			v.Add(n)
+		}
+		return
+	}
+
+	v.appendCodes(src, start, end)
+}
+
+// appendCodes adds the encoded values of src from
+// index start to end-1 to v without decoding them.
+func (v *Vector) appendCodes(src *Vector, start, end int) {
+	if end <= start {
+		return
+	} else if !v.initialized {
+		v.init()
+	}
+
+	words := src.bits.Bits()
+	code := make([]uint64, (maxCodeBits+63)>>6)
+
+	idx := src.select11(start + 1)
+	for i := start; i < end; i++ {
+		next := nextStart(words, idx)
+
+		// Exclude padding bits since a
+		// code never ends with 11
+		size := next - idx
+		if getBits(words, next-2, 2) == 0x3 {
+			size -= 2
+		}
+
+		n := (size + 63) >> 6
+		for j := 0; j < n; j++ {
+			code[j] = getBits(words, idx+j<<6, 64)
+		}
+		code[n-1] = getBits(words, idx+(n-1)<<6, size-(n-1)<<6)
+
+		v.addCode(code[:n], size)
+		idx = next
+	}
+	v.summed = false
+}
+
+// addCode appends the encoded value fc
+// with length lfc to the bit array.
+func (v *Vector) addCode(fc []uint64, lfc int) {
+	if !v.indexBuilt() {
+		v.buildIndex()
+	}
+
+	v.length++
+	idx := v.bits.Len() - 3
+
+	if lfc > 64 {
+		v.bits.Insert(idx, fc[0], 64)
+		lfc -= 64
+
+		for _, f := range fc[1 : len(fc)-1] {
+			v.bits.Add(f, 64)
+			lfc -= 64
+		}
+		v.bits.Add(fc[len(fc)-1], lfc)
+	} else {
+		v.bits.Insert(idx, fc[0], lfc)
+	}
+
+	// Add bit padding so that pairs
+	// of 1 (11s) don't get separated
+	// by array boundaries. Since a code
+	// only has an 11 at its beginning,
+	// this only happens if the next code
+	// starts at the last bit of a word.
+	if !v.compact && (v.bits.Len()-1)&63 == 62 {
+		v.bits.Add(0x3, 2)
+	}
+
+	v.popcount++
+	vlen := v.bits.Len()
+
+	// Add a rank sample for every block boundary
+	// crossed by the code and its padding bits.
+	// The new value is only counted if it starts
+	// before the boundary.
+	for v.ranks.length()*v.sr < vlen {
+		rank := v.popcount
+		if idx >= v.ranks.length()*v.sr {
+			rank--
+		}
+		v.ranks.add(rank)
+	}
+
+	if v.popcount-(v.indices.length()*v.ss) > 0 {
+		v.indices.add(idx ^ 0x3F)
+	}
+
+	// Add terminating bits so that
+	// the last value can be decoded
+	v.bits.Add(0x3, 3)
+
+	v.hashed = false
+}
+
+// Set replaces the value at index i with n. Since the new
+// value may have a different code length, the codes after
+// it are moved and their rank and select samples rebuilt.
+// The samples before the value are kept.
+func (v *Vector) Set(i, n int) {
+	if i >= v.length {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	} else if n > MaxValue || n < MinValue {
+		panic("fibvec: input is not in the range of encodable values")
+	} else if v.base != 0 && (n < v.base || uint(n-v.base) > MaxValue) {
+		panic("fibvec: input is not in the range of the vector base")
+	} else if v.dict != nil && !v.inDict(n) {
+		panic("fibvec: input is not in the dictionary")
+	} else if v.flagBits != 0 && !v.fitsFlags(n) {
+		panic("fibvec: input is not in the range of flagged values")
+	}
+
+	old, flag := v.GetWithFlag(i)
+	summed, sum, sumOK := v.summed, v.sum, v.sumOK
+
+	// Copy the codes after
+	// i without padding bits
+	tail := newVectorLike(v)
+	tail.compact = true
+	tail.appendCodes(v, i+1, v.length)
+
+	v.Truncate(i)
+	v.add(n, flag)
+	v.appendCodes(tail, 0, tail.length)
+
+	// Adjust the sum instead of
+	// decoding all the values
+	v.summed = false
+	if summed && sumOK {
+		s, ok := addInt(sum, -old)
+		if ok {
+			s, ok = addInt(s, n)
+		}
+		v.sum, v.sumOK, v.summed = s, true, ok
+	}
+}
+
+// Reset removes all the values from the vector while keeping
+// its settings. The rank and select samples keep their storage
+// and the bit array is allocated with its previous size so that
+// adding the same amount of values doesn't grow them again.
+func (v *Vector) Reset() {
+	if !v.initialized {
+		v.init()
+		return
+	} else if !v.indexBuilt() {
+		v.buildIndex()
+	}
+
+	v.bits = bit.NewArray(v.bits.Len())
+	v.bits.Add(0x3, 3)
+
+	v.ranks.truncate(1)
+	v.ranks.set(0, 0)
+	v.indices.truncate(1)
+	v.indices.set(0, 0)
+
+	v.length = 0
+	v.popcount = 0
+
+	v.sum = 0
+	v.sumOK = true
+	v.summed = true
+	v.hashed = false
+}
+
+// Clone returns a copy of the vector that
+// doesn't share any storage with v.
+func (v *Vector) Clone() *Vector {
+	vec := *v
+	vec.dictIndex = nil
+	if !v.initialized {
+		return &vec
+	}
+
+	vec.bits = copyBits(v.bits, v.bits.Len(), 0)
+	if v.indexBuilt() {
+		vec.ranks = v.ranks.clone()
+		vec.indices = v.indices.clone()
+	}
+	if v.dict != nil {
+		vec.dict = append([]int{}, v.dict...)
+	}
+
+	return &vec
+}
+
+// copyBits returns a new bit array containing the first
+// n bits of src with room for extra more bits.
+func copyBits(src *bit.Array, n, extra int) *bit.Array {
+	words := src.Bits()
+
+	bits := bit.NewArray(n + extra)
+	for j := 0; j < n; j += 64 {
+		size := n - j
+		if size > 64 {
+			size = 64
+		}
+		bits.Add(getBits(words, j, size), size)
+	}
+
+	return bits
+}
+
+// Truncate removes the values from index n onwards so that
+// only the first n values are left. The bit array is cut at
+// the beginning of the nth value and the rank and select
+// samples after it are dropped. The samples before it stay
+// valid since they don't depend on the values after them.
+func (v *Vector) Truncate(n int) {
+	if n > v.length {
+		panic("fibvec: index out of bounds")
+	} else if n < 0 {
+		panic("fibvec: invalid index")
+	} else if n == v.length {
+		return
+	}
+
+	// The bits before the nth value
+	// including the padding bits are
+	// the same as when it was added.
+	idx := v.select11(n + 1)
+	v.bits = copyBits(v.bits, idx, 3)
+	v.bits.Add(0x3, 3)
+
+	nranks := (idx + v.sr - 1) / v.sr
+	if nranks < 1 {
+		nranks = 1
+	}
+	v.ranks.truncate(nranks)
+
+	nindices := (n + v.ss - 1) / v.ss
+	if nindices < 1 {
+		nindices = 1
+	}
+	v.indices.truncate(nindices)
+
+	v.length = n
+	v.popcount = n
+	v.summed = false
+	v.hashed = false
+}
+
+// Get returns the value at index i. The value is decoded
+// from a local copy of its bytes so Get can be called from
+// multiple goroutines as long as the vector is not modified.
+func (v *Vector) Get(i int) int {
+	return v.value(v.getEncoded(i) >> v.flagBits)
+}
+
+// getEncoded returns the encoded value at index
+// i before it is mapped back to the stored value.
+func (v *Vector) getEncoded(i int) int {
+	if i >= v.length {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	return v.decodeAt(v.select11(i + 1))
+}
+
+// decodeAt returns the encoded value
+// that begins at bit idx.
+func (v *Vector) decodeAt(idx int) int {
+	// Copy the bytes spanned by the value so that
+	// the bits before it can be zeroed out without
+	// modifying the bit array.
+	words := v.bits.Bits()
+	first := idx &^ 7
+
+	var buf [maxCodeBytes + 3]byte
+	binary.LittleEndian.PutUint64(buf[:], getBits(words, first, 64))
+	binary.LittleEndian.PutUint64(buf[8:], getBits(words, first+64, 64))
+	buf[0] &= ^byte((1 << uint(idx&7)) - 1)
+
+	n, ok := fibdecodeOne(buf[:])
+	if !ok {
+		panic("fibvec: invalid code")
+	}
+
+	return fromSignMagnitude(n)
+}
+
+// GetUint64 returns the value at index i as an
+// unsigned integer. This panics if it is negative.
+func (v *Vector) GetUint64(i int) uint64 {
+	n := v.Get(i)
+	if n < 0 {
+		panic("fibvec: value is negative")
+	}
+
+	return uint64(n)
+}
+
+// GetValues returns the values from start to end-1.
+func (v *Vector) GetValues(start, end int) []int {
+	if end-start <= 0 {
+		panic("fibvec: end must be greater than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > v.length {
+		panic("fibvec: index out of bounds")
+	}
+
+	return v.decode(start, end-start)
+}
+
+// maxSkipBits is roughly the number of bits that GetMany
+// decodes and discards to reach the next requested value
+// before it locates that value using select11 instead.
+const maxSkipBits = 256
+
+// GetMany returns the values at the given indices in the same
+// order. The indices are visited in ascending order so that the
+// vector is traversed once, and values that are close together
+// are decoded in a single pass instead of being located one by
+// one. Indices may be repeated.
+func (v *Vector) GetMany(indices []int) []int {
+	for _, i := range indices {
+		if i >= v.length {
+			panic("fibvec: index out of bounds")
+		} else if i < 0 {
+			panic("fibvec: invalid index")
+		}
+	}
+
+	// order contains the positions of
+	// the indices in ascending order
+	m := len(indices)
+	order := make([]int, m)
+	for k := range order {
+		order[k] = k
+	}
+
+	switch {
+	case sort.IntsAreSorted(indices):
+	case v.length <= math.MaxInt64/m:
+		// Sort each index along with its
+		// position packed in a single int
+		// which is faster than sort.Slice
+		for k, i := range indices {
+			order[k] = i*m + k
+		}
+		sort.Ints(order)
+		for j := range order {
+			order[j] %= m
+		}
+	default:
+		sort.Slice(order, func(a, b int) bool {
+			return indices[order[a]] < indices[order[b]]
+		})
+	}
+
+	// Estimate the number of values in
+	// maxSkipBits from the average length
+	// of the codes
+	maxSkip := 0
+	if v.length > 0 {
+		maxSkip = maxSkipBits * v.length / v.bits.Len()
+	}
+
+	words := v.bits.Bits()
+	d := decoder{base: v.base, dict: v.dict, flagBits: v.flagBits}
+
+	// pos is the index of the value
+	// that d.next returns next
+	pos, n := -1, 0
+
+	results := make([]int, m)
+	for _, k := range order {
+		i := indices[k]
+		if pos < 0 || i-pos > maxSkip {
+			d.resetWords(words, v.select11(i+1))
+			pos = i
+		}
+		for ; pos <= i; pos++ {
+			n, _ = d.next()
+		}
+		results[k] = n
+	}
+
+	return results
+}
+
+// GetValuesInto is like GetValues but decodes the values into
+// dst which is grown only if it is too small. This returns dst
+// resliced to hold the values. Passing the result of a previous
+// call avoids allocating a slice for every call.
+func (v *Vector) GetValuesInto(start, end int, dst []int) []int {
+	if end-start <= 0 {
+		panic("fibvec: end must be greater than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > v.length {
+		panic("fibvec: index out of bounds")
+	}
+
+	return v.decodeAppend(dst[:0], start, end-start)
+}
+
+// GetValuesChecked is like GetValues but returns a *DecodeError
+// instead of wrapping or dropping a value that cannot be decoded
+// or doesn't fit in an int. The values before it are returned.
+func (v *Vector) GetValuesChecked(start, end int) ([]int, error) {
+	if end-start <= 0 {
+		panic("fibvec: end must be greater than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > v.length {
+		panic("fibvec: index out of bounds")
+	}
+
+	results := make([]int, 0, end-start)
+
+	d := v.decoder(start)
+	for i := start; i < end; i++ {
+		n, ok := d.next()
+		if !ok && d.invalid {
+			return results, &DecodeError{i, "value out of range"}
+		} else if !ok {
+			return results, &DecodeError{i, "unexpected end of data"}
+		}
+
+		results = append(results, n)
+	}
+
+	return results, nil
+}
+
+// CollectInto calls collect for each value from start to end-1
+// in order. Unlike GetValues, this doesn't allocate a slice for
+// the values.
+func (v *Vector) CollectInto(start, end int, collect func(int)) {
+	if end-start <= 0 {
+		panic("fibvec: end must be greater than start")
+	} else if start < 0 || end < 0 {
+		panic("fibvec: invalid index")
+	} else if end > v.length {
+		panic("fibvec: index out of bounds")
+	}
+
+	d := v.decoder(start)
+	for i := start; i < end; i++ {
+		n, _ := d.next()
+		collect(n)
+	}
+}
+
+// BitOffset returns the index of the first bit
+// of the ith value in the bit array.
+func (v *Vector) BitOffset(i int) int {
+	if i >= v.length {
+		panic("fibvec: index out of bounds")
+	} else if i < 0 {
+		panic("fibvec: invalid index")
+	}
+
+	return v.select11(i + 1)
+}
+
+// IndicesInBitRange returns the indices of the first and last
+// values that begin within bits bitStart to bitEnd-1 of the bit
+// array. This is the inverse of BitOffset and can be used to map
+// a byte or bit window back to the values in it. If no value
+// begins in the range, lastIdx is firstIdx-1.
+func (v *Vector) IndicesInBitRange(bitStart, bitEnd int) (firstIdx, lastIdx int) {
+	if bitStart < 0 || bitEnd < bitStart {
+		panic("fibvec: invalid bit range")
+	} else if !v.initialized {
+		v.init()
+	}
+
+	// Exclude the terminating bits
+	vlen := v.bits.Len() - 3
+	if bitEnd > vlen {
+		bitEnd = vlen
+	}
+	if bitStart > bitEnd {
+		bitStart = bitEnd
+	}
+
+	return v.rank11(bitStart), v.rank11(bitEnd) - 1
+}
+
+// GetValuesFromOffset returns count values starting from the
+// value that begins at bitOffset, which is usually obtained
+// using BitOffset. This skips locating the first value which
+// makes it faster than GetValues. This panics if bitOffset is
+// not the beginning of a value or if there are less than count
+// values after it.
+func (v *Vector) GetValuesFromOffset(bitOffset, count int) []int {
+	words := v.bits.Bits()
+	if count <= 0 {
+		panic("fibvec: count must be greater than zero")
+	} else if bitOffset < 0 || bitOffset >= v.bits.Len()-3 {
+		panic("fibvec: invalid bit offset")
+	} else if getBits(words, bitOffset, 3) != 0x3 {
+		panic("fibvec: bit offset is not the beginning of a value")
+	}
+
+	d := decoder{base: v.base, dict: v.dict, flagBits: v.flagBits}
+	d.resetWords(words, bitOffset)
+
+	results := make([]int, 0, count)
+	for len(results) < count {
+		n, ok := d.next()
+		if !ok {
+			panic("fibvec: index out of bounds")
+		}
+		results = append(results, n)
+	}
+
+	return results
+}
+
+// decode returns count values starting from
+// the ith value. The values are copied into a
+// new slice so that the returned slice doesn't
+// refer to the bit array.
+func (v *Vector) decode(i, count int) []int {
+	return v.decodeAppend(make([]int, 0, count), i, count)
+}
+
+// decodeAppend is like decode but appends
+// the values to dst instead of a new slice.
+func (v *Vector) decodeAppend(dst []int, i, count int) []int {
+	idx := v.select11(i + 1)
+
+	// The decoder ignores the bits before idx
+	// in a local copy of the first byte so the
+	// shared bit array is never modified.
+	d := decoder{base: v.base, dict: v.dict, flagBits: v.flagBits}
+	d.resetWords(v.bits.Bits(), idx)
+
+	decoded := 0
+	for ; decoded < count; decoded++ {
+		n, ok := d.next()
+		if !ok {
+			break
+		}
+		dst = append(dst, n)
+	}
+
+	if decoded < count && DebugLogger != nil {
+		debugf("short buffer, decoded %d of %d values from index %d", decoded, count, i)
+	}
+
+	return dst
+}
+
+// Errors returned by TryGet, TryGetValues, and GetValuesSafe
+// if the given index or range is invalid.
+var (
+	ErrIndexOutOfBounds = errors.New("fibvec: index out of bounds")
+	ErrInvalidIndex     = errors.New("fibvec: invalid index")
+	ErrInvalidRange     = errors.New("fibvec: end must be greater than start")
+)
+
+// DecodeError is returned when
+// a value cannot be decoded.
+type DecodeError struct {
+	// Index is the index of the
+	// value that failed to decode.
+	Index int
+
+	// Reason describes the failure.
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("fibvec: cannot decode value at index %d (%s)", e.Index, e.Reason)
+}
+
+// TryGet is like Get but returns ErrIndexOutOfBounds or
+// ErrInvalidIndex instead of panicking if i is not a valid
+// index, and a *DecodeError if the value cannot be decoded.
+func (v *Vector) TryGet(i int) (n int, err error) {
+	if i >= v.length {
+		return 0, ErrIndexOutOfBounds
+	} else if i < 0 {
+		return 0, ErrInvalidIndex
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			n, err = 0, &DecodeError{i, fmt.Sprint(r)}
+		}
+	}()
+
+	return v.Get(i), nil
+}
+
+// TryGetValues is the same as GetValuesSafe.
+// It is provided for symmetry with TryGet.
+func (v *Vector) TryGetValues(start, end int) ([]int, error) {
+	return v.GetValuesSafe(start, end)
+}
+
+// GetValuesSafe is like GetValues but returns an error instead of
+// panicking if the range is invalid or if the values cannot be
+// decoded, eg., if the vector is corrupted. A *DecodeError is
+// returned in the latter case together with the values that are
+// successfully decoded before the failure.
+func (v *Vector) GetValuesSafe(start, end int) (values []int, err error) {
+	if end-start <= 0 {
+		return nil, ErrInvalidRange
+	} else if start < 0 || end < 0 {
+		return nil, ErrInvalidIndex
+	} else if end > v.length {
+		return nil, ErrIndexOutOfBounds
+	}
 
-	// indices[i] points to the
-	// beginning of the uint64 (LSB)
-	// that contains the (i*ss)+1th
-	// pair of bits.
-	indices []int
+	// Corrupted rank and select samples
+	// can make select11 index out of range
+	defer func() {
+		if r := recover(); r != nil {
+			err = &DecodeError{start + len(values), fmt.Sprint(r)}
+		}
+	}()
+
+	d := v.decoder(start)
+	values = make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		n, ok := d.next()
+		if !ok && d.invalid {
+			return values, &DecodeError{i, "invalid code"}
+		} else if !ok {
+			return values, &DecodeError{i, "unexpected end of data"}
+		}
 
-	popcount int
+		values = append(values, n)
+	}
 
-	length      int
-	initialized bool
+	return values, nil
 }
 
-// Initialize vector
-func (v *Vector) init() {
-	v.bits = bit.NewArray(0)
-	v.ranks = make([]int, 1)
-	v.indices = make([]int, 1)
+// ToSlice returns all the values stored in the vector. The
+// values are decoded in a single pass over the bit array. This
+// returns an empty slice if the vector is empty.
+func (v *Vector) ToSlice() []int {
+	values := make([]int, 0, v.length)
 
-	// Add terminating bits
-	v.bits.Add(0x3, 3)
+	d := v.decoder(0)
+	for i := 0; i < v.length; i++ {
+		n, _ := d.next()
+		values = append(values, n)
+	}
 
-	v.initialized = true
+	return values
 }
 
-// NewVector creates a new vector.
-func NewVector() *Vector {
-	vec := &Vector{}
-	vec.init()
-	return vec
+// ToInt64Buffer returns all the values stored in the vector
+// in a contiguous int64 buffer which can be used to build
+// columnar arrays such as Apache Arrow's. This returns a
+// *DecodeError if a value cannot be decoded or is outside
+// the range of encodable values.
+func (v *Vector) ToInt64Buffer() ([]int64, error) {
+	buf := make([]int64, 0, v.length)
+
+	d := v.decoder(0)
+	for i := 0; i < v.length; i++ {
+		n, ok := d.next()
+		if !ok && d.invalid {
+			return buf, &DecodeError{i, "value out of range"}
+		} else if !ok {
+			return buf, &DecodeError{i, "unexpected end of data"}
+		}
+
+		buf = append(buf, int64(n))
+	}
+
+	return buf, nil
 }
 
-// Add adds an integer to the vector.
-func (v *Vector) Add(n int) {
-	if n > MaxValue || n < MinValue {
-		panic("fibvec: input is not in the range of encodable values")
-	} else if !v.initialized {
+// decoder returns a decoder that starts
+// from the ith value of the vector.
+func (v *Vector) decoder(i int) *decoder {
+	if !v.initialized {
 		v.init()
 	}
 
-	// Convert to sign-magnitude representation
-	// so that "small" negative numbers such as
-	// -1, -2, -3... can be encoded
-	nn := toSignMagnitude(n)
+	idx := 0
+	if i < v.length {
+		idx = v.select11(i + 1)
+	}
 
-	v.length++
-	idx := v.bits.Len() - 3
-	fc, lfc := fibencode(nn)
-	size := lfc
+	d := &decoder{base: v.base, dict: v.dict, flagBits: v.flagBits}
+	d.resetWords(v.bits.Bits(), idx)
 
-	if lfc > 64 {
-		v.bits.Insert(idx, fc[0], 64)
-		lfc -= 64
+	return d
+}
 
-		for _, f := range fc[1 : len(fc)-1] {
-			v.bits.Add(f, 64)
-			lfc -= 64
-		}
-		v.bits.Add(fc[len(fc)-1], lfc)
-	} else {
-		v.bits.Insert(idx, fc[0], lfc)
+// Freeze compacts the vector by removing the padding bits
+// inserted by Add to keep pairs of 1 from being separated by
+// array boundaries. Values added after Freeze are also packed
+// without padding.
+func (v *Vector) Freeze() {
+	if v.compact {
+		return
 	}
 
-	// Add bit padding so that pairs
-	// of 1 (11s) don't get separated
-	// by array boundaries.
-	if (v.bits.Len()-1)&63 == 62 {
-		v.bits.Add(0x3, 2)
-	}
+	summed, sum, sumOK := v.summed, v.sum, v.sumOK
 
-	v.popcount++
-	vlen := v.bits.Len()
+	vec := newVectorLike(v)
+	vec.compact = true
+	vec.appendCodes(v, 0, v.length)
+	vec.summed, vec.sum, vec.sumOK = summed, sum, sumOK
+	*v = *vec
+}
 
-	lenranks := len(v.ranks)
-	overflow := vlen - (lenranks * sr)
-	if overflow > 0 {
-		v.ranks = append(v.ranks, 0)
-		v.ranks[lenranks] = v.popcount
-		if size <= overflow {
-			v.ranks[lenranks]--
-		}
+// Normalize rebuilds the bit array and the rank and select
+// samples of the vector as if its values were added one by one.
+// The codes are copied without decoding them but the padding
+// bits are recomputed, so the result is canonical regardless of
+// how the vector was edited or where its bits came from. This
+// makes Equal and ContentHash reliable for comparing vectors.
+func (v *Vector) Normalize() {
+	if !v.initialized {
+		v.init()
+		return
 	}
 
-	lenidx := len(v.indices)
-	if v.popcount-(lenidx*ss) > 0 {
-		v.indices = append(v.indices, 0)
-		v.indices[lenidx] = idx ^ 0x3F
-	}
+	summed, sum, sumOK := v.summed, v.sum, v.sumOK
 
-	// Add terminating bits so that
-	// the last value can be decoded
-	v.bits.Add(0x3, 3)
+	vec := newVectorLike(v)
+	vec.appendCodes(v, 0, v.length)
+	vec.summed, vec.sum, vec.sumOK = summed, sum, sumOK
+	*v = *vec
 }
 
-// Get returns the value at index i.
-func (v *Vector) Get(i int) int {
-	if i >= v.length {
-		panic("fibvec: index out of bounds")
-	} else if i < 0 {
-		panic("fibvec: invalid index")
+// Partition splits a sorted vector into two vectors. The first
+// contains the values that are less than threshold and the second
+// contains the rest. The vector must be sorted in ascending order.
+func (v *Vector) Partition(threshold int) (below, atOrAbove *Vector) {
+	below = newVectorLike(v)
+	atOrAbove = newVectorLike(v)
+	if v.length == 0 {
+		return
 	}
 
-	idx := v.select11(i + 1)
-	bits := v.bits.Bits()
+	idx := sort.Search(v.length, func(i int) bool {
+		return v.Get(i) >= threshold
+	})
 
-	// Temporary store and
-	// zero out extra bits
-	aidx := idx >> 6
-	bidx := idx & 63
-	temp := bits[aidx]
-	bits[aidx] &= ^((1 << uint(bidx)) - 1)
+	below.appendCodes(v, 0, idx)
+	atOrAbove.appendCodes(v, idx, v.length)
 
-	// Transform to bytes
-	bytes := byteSliceFromUint64Slice(bits)
-	bytes = bytes[idx>>3:]
+	return
+}
+
+// RangeQuery returns the values that are greater than or equal
+// to lo and less than or equal to hi. The vector must be sorted
+// in ascending order.
+func (v *Vector) RangeQuery(lo, hi int) []int {
+	start := v.LowerBound(lo)
+	end := v.UpperBound(hi)
 
-	// This makes sure that the last number is decoded
-	if len(bytes) < 16 {
-		bytes = append(bytes, []byte{0, 0}...)
+	if end <= start {
+		return []int{}
 	}
-	result := fibdecode(bytes, 1)
 
-	// Restore bits
-	bits[aidx] = temp
+	return v.GetValues(start, end)
+}
 
-	return result[0]
+// SearchSorted returns the index of the first value that is equal
+// to target and true. If there is no such value, this returns the
+// index where target would be inserted and false. The vector must
+// be sorted in ascending order, otherwise the result is undefined.
+func (v *Vector) SearchSorted(target int) (index int, found bool) {
+	index = v.LowerBound(target)
+	found = index < v.length && v.Get(index) == target
+	return
 }
 
-// GetValues returns the values from start to end-1.
-func (v *Vector) GetValues(start, end int) []int {
+// LowerBound returns the index of the first value that is greater
+// than or equal to target, or Len if there is none. The vector must
+// be sorted in ascending order, otherwise the result is undefined.
+func (v *Vector) LowerBound(target int) int {
+	return sort.Search(v.length, func(i int) bool {
+		return v.Get(i) >= target
+	})
+}
+
+// UpperBound returns the index of the first value that is greater
+// than target, or Len if there is none. The vector must be sorted
+// in ascending order, otherwise the result is undefined.
+func (v *Vector) UpperBound(target int) int {
+	return sort.Search(v.length, func(i int) bool {
+		return v.Get(i) > target
+	})
+}
+
+// SerializeRange returns the gob encoded vector containing
+// the values from start to end-1. This copies the encoded
+// values directly instead of decoding and encoding them again.
+func (v *Vector) SerializeRange(start, end int) ([]byte, error) {
 	if end-start <= 0 {
-		panic("fibvec: end must be greater than start")
+		return nil, fmt.Errorf("fibvec: end must be greater than start")
 	} else if start < 0 || end < 0 {
-		panic("fibvec: invalid index")
+		return nil, fmt.Errorf("fibvec: invalid index")
 	} else if end > v.length {
-		panic("fibvec: index out of bounds")
-	}
-
-	idx := v.select11(start + 1)
-	bits := v.bits.Bits()
-
-	// Temporary store and
-	// zero out extra bits
-	aidx := idx >> 6
-	bidx := idx & 63
-	temp := bits[aidx]
-	bits[aidx] &= ^((1 << uint(bidx)) - 1)
-
-	// Transform to bytes
-	bytes := byteSliceFromUint64Slice(bits)
-	bytes = bytes[idx>>3:]
-
-	// This makes sure that the last number is decoded
-	if len(bytes) < 16 {
-		bytes = append(bytes, []byte{0, 0}...)
+		return nil, fmt.Errorf("fibvec: index out of bounds")
 	}
-	results := fibdecode(bytes, end-start)
 
-	// Restore bits
-	bits[aidx] = temp
+	vec := newVectorLike(v)
+	vec.appendCodes(v, start, end)
 
-	return results
+	return vec.GobEncode()
 }
 
 // Size returns the vector size in bytes.
 func (v *Vector) Size() int {
-	sizeofInt := int(unsafe.Sizeof(int(0)))
+	sizeofInt := bits.UintSize / 8
 
 	size := v.bits.Size()
-	size += len(v.ranks) * sizeofInt
-	size += len(v.indices) * sizeofInt
+	size += v.ranks.size()
+	size += v.indices.size()
+	size += len(v.dict) * sizeofInt
 
 	return size
 }
 
+// FixedWidthSize returns the size in bytes of the values if
+// they are stored in a packed array where each value uses as
+// many bits as the value with the largest magnitude, plus a
+// sign bit if there are negative values. Comparing this with
+// Size shows whether fibonacci coding saves space over simple
+// bit packing.
+func (v *Vector) FixedWidthSize() int {
+	maxmag := uint64(0)
+	signed := false
+	for n := range v.Values() {
+		mag := uint64(n)
+		if n < 0 {
+			mag = uint64(-n)
+			signed = true
+		}
+		if mag > maxmag {
+			maxmag = mag
+		}
+	}
+
+	width := bits.Len64(maxmag)
+	if signed {
+		width++
+	}
+
+	return (v.length*width + 7) / 8
+}
+
 // Len returns the number of values stored.
 func (v *Vector) Len() int {
 	return v.length
 }
 
+// ContentHash returns a 64-bit FNV-1a hash of the encoded
+// values. Vectors that store the same values using the same
+// settings have equal hashes, so this can be used as a cheap
+// equality test. Different vectors can also have equal hashes
+// albeit rarely, so a match must be confirmed by comparing the
+// values if a false positive is unacceptable. The hash is cached
+// until the vector is modified.
+func (v *Vector) ContentHash() uint64 {
+	if v.hashed {
+		return v.hash
+	} else if !v.initialized {
+		v.init()
+	}
+
+	buf := make([]byte, 8)
+	h := fnv.New64a()
+
+	binary.LittleEndian.PutUint64(buf, uint64(v.length))
+	h.Write(buf)
+	binary.LittleEndian.PutUint64(buf, uint64(v.base))
+	h.Write(buf)
+	for _, n := range v.dict {
+		binary.LittleEndian.PutUint64(buf, uint64(n))
+		h.Write(buf)
+	}
+	if v.flagBits != 0 {
+		binary.LittleEndian.PutUint64(buf, uint64(v.flagBits))
+		h.Write(buf)
+	}
+
+	bits := v.bits.Bits()
+	nwords := (v.bits.Len() + 63) >> 6
+	if nwords < len(bits) {
+		bits = bits[:nwords]
+	}
+	for _, b := range bits {
+		binary.LittleEndian.PutUint64(buf, b)
+		h.Write(buf)
+	}
+
+	v.hash = h.Sum64()
+	v.hashed = true
+
+	return v.hash
+}
+
 func checkErr(err ...error) error {
 	for _, e := range err {
 		if e != nil {
@@ -228,18 +1491,56 @@ func checkErr(err ...error) error {
 
 // GobEncode encodes this vector into gob streams.
 func (v *Vector) GobEncode() ([]byte, error) {
+	if !v.initialized {
+		v.init()
+	}
+	sum, sumOK := v.Sum()
+
+	ranks, indices := v.ranks.ints(), v.indices.ints()
+	if v.implicitIndex {
+		ranks, indices = []int{}, []int{}
+	}
+
 	buf := &bytes.Buffer{}
 	enc := gob.NewEncoder(buf)
 
 	err := checkErr(
+		enc.Encode(FormatVersion),
+		enc.Encode(v.flags()),
 		enc.Encode(v.bits),
-		enc.Encode(v.ranks),
-		enc.Encode(v.indices),
-		enc.Encode(v.popcount),
-		enc.Encode(v.length),
+		enc.Encode(ranks),
+		enc.Encode(indices),
 		enc.Encode(v.initialized),
+		enc.Encode(v.base),
 	)
 
+	if err == nil && !v.implicitLength {
+		err = checkErr(
+			enc.Encode(v.popcount),
+			enc.Encode(v.length),
+		)
+	}
+
+	if err == nil {
+		err = checkErr(
+			enc.Encode(sum),
+			enc.Encode(sumOK),
+		)
+	}
+
+	if err == nil && v.dict != nil {
+		err = enc.Encode(v.dict)
+	}
+	if err == nil && v.flagBits != 0 {
+		err = enc.Encode(v.flagBits)
+	}
+	if err == nil && (v.sr != sr || v.ss != ss) {
+		err = checkErr(
+			enc.Encode(v.sr),
+			enc.Encode(v.ss),
+		)
+	}
+
 	if err != nil {
 		err = fmt.Errorf("fibvec: encode failed (%v)", err)
 	}
@@ -247,24 +1548,230 @@ func (v *Vector) GobEncode() ([]byte, error) {
 	return buf.Bytes(), err
 }
 
-// GobDecode populates this vector from gob streams.
+// GobDecode populates this vector from gob streams. This
+// returns an error if the decoded vector is inconsistent, in
+// which case the vector is left unchanged. The values are not
+// decoded so invalid codes are only found by ValidateCodes.
 func (v *Vector) GobDecode(data []byte) error {
-	buf := bytes.NewReader(data)
-	dec := gob.NewDecoder(buf)
+	vec := &Vector{}
+	err := vec.decodeFields(data)
+	if err == nil {
+		err = vec.check()
+		if err != nil && DebugLogger != nil {
+			debugf("decoded vector is inconsistent (%v)", err)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("fibvec: decode failed (%w)", err)
+	}
+
+	*v = *vec
+	return nil
+}
+
+// decodeFields decodes the vector
+// fields from the gob stream.
+func (v *Vector) decodeFields(data []byte) error {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+
+	// Version 1 streams start
+	// with the bit array instead
+	version := 0
+	if dec.Decode(&version) != nil {
+		return v.decodeFieldsV1(data)
+	}
+
+	var err error
+	switch version {
+	case 2:
+		err = v.decodeHeaderV2(dec)
+	case FormatVersion:
+		err = v.decodeHeader(dec)
+	default:
+		err = fmt.Errorf("unsupported format version %d", version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if v.implicitIndex {
+		v.ranks = sampleArray{}
+		v.indices = sampleArray{}
+	}
+
+	if v.implicitLength {
+		// Exclude the terminating bits
+		v.length = countStarts(v.bits.Bits(), v.bits.Len()) - 1
+		v.popcount = v.length
+	} else {
+		err = checkErr(
+			dec.Decode(&v.popcount),
+			dec.Decode(&v.length),
+		)
+	}
+
+	// The cached sum can't be checked without
+	// decoding the values so it is skipped and
+	// computed again by Sum when needed
+	v.summed = false
+	if err == nil {
+		sum, sumOK := 0, false
+		err = checkErr(
+			dec.Decode(&sum),
+			dec.Decode(&sumOK),
+		)
+	}
+
+	v.dictIndex = nil
+	if err == nil && v.dict != nil {
+		err = dec.Decode(&v.dict)
+	}
+	if err == nil && v.flagBits != 0 {
+		err = dec.Decode(&v.flagBits)
+		if err == nil && (v.flagBits == 0 || v.flagBits > maxFlagBits) {
+			err = fmt.Errorf("invalid number of flag bits %d", v.flagBits)
+		}
+	}
+	if err == nil && v.sr == 0 {
+		err = checkErr(
+			dec.Decode(&v.sr),
+			dec.Decode(&v.ss),
+		)
+		if err == nil && !validSampling(v.sr, v.ss) {
+			err = fmt.Errorf("invalid sampling block sizes %d and %d", v.sr, v.ss)
+		}
+	}
+
+	return err
+}
 
+// decodeHeader decodes the mode flags, the bit
+// array, and the rank and select samples.
+func (v *Vector) decodeHeader(dec *gob.Decoder) error {
+	flags := uint64(0)
+	if err := dec.Decode(&flags); err != nil {
+		return err
+	} else if flags&^knownFlags != 0 {
+		return fmt.Errorf("unknown mode flags %#x", flags&^knownFlags)
+	}
+
+	v.compact = flags&flagCompact != 0
+	v.implicitLength = flags&flagImplicitLength != 0
+	v.implicitIndex = flags&flagImplicitIndex != 0
+
+	// Mark that a dictionary
+	// follows the cached sum
+	v.dict = nil
+	if flags&flagDictionary != 0 {
+		v.dict = []int{}
+	}
+
+	// Likewise for the number of flag bits
+	v.flagBits = 0
+	if flags&flagElementFlags != 0 {
+		v.flagBits = 1
+	}
+
+	// and the sampling block sizes
+	v.sr, v.ss = sr, ss
+	if flags&flagSampling != 0 {
+		v.sr = 0
+	}
+
+	var ranks, indices []int
 	v.bits = bit.NewArray(0)
 	err := checkErr(
 		dec.Decode(v.bits),
-		dec.Decode(&v.ranks),
-		dec.Decode(&v.indices),
-		dec.Decode(&v.popcount),
-		dec.Decode(&v.length),
+		dec.Decode(&ranks),
+		dec.Decode(&indices),
 		dec.Decode(&v.initialized),
+		dec.Decode(&v.base),
 	)
+	v.ranks, v.indices = samplesOf(ranks), samplesOf(indices)
 
-	if err != nil {
-		err = fmt.Errorf("fibvec: decode failed (%v)", err)
+	return err
+}
+
+// decodeHeaderV2 is like decodeHeader
+// but for version 2 streams which store
+// the modes as separate fields.
+func (v *Vector) decodeHeaderV2(dec *gob.Decoder) error {
+	v.bits = bit.NewArray(0)
+	v.dict = nil
+	v.flagBits = 0
+	v.sr, v.ss = sr, ss
+
+	var ranks, indices []int
+	err := checkErr(
+		dec.Decode(v.bits),
+		dec.Decode(&ranks),
+		dec.Decode(&indices),
+		dec.Decode(&v.initialized),
+		dec.Decode(&v.compact),
+		dec.Decode(&v.base),
+		dec.Decode(&v.implicitLength),
+	)
+
+	// Empty rank samples means
+	// that the index is omitted
+	v.implicitIndex = len(ranks) == 0
+	v.ranks, v.indices = samplesOf(ranks), samplesOf(indices)
+
+	return err
+}
+
+// flags returns the mode flags of the vector.
+func (v *Vector) flags() uint64 {
+	flags := uint64(0)
+	if v.compact {
+		flags |= flagCompact
+	}
+	if v.implicitLength {
+		flags |= flagImplicitLength
+	}
+	if v.implicitIndex {
+		flags |= flagImplicitIndex
 	}
+	if v.dict != nil {
+		flags |= flagDictionary
+	}
+	if v.flagBits != 0 {
+		flags |= flagElementFlags
+	}
+	if v.sr != sr || v.ss != ss {
+		flags |= flagSampling
+	}
+
+	return flags
+}
+
+// decodeFieldsV1 decodes the vector
+// fields from a version 1 gob stream.
+func (v *Vector) decodeFieldsV1(data []byte) error {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+
+	v.bits = bit.NewArray(0)
+	v.compact = false
+	v.base = 0
+	v.implicitLength = false
+	v.implicitIndex = false
+	v.dict = nil
+	v.flagBits = 0
+	v.sr, v.ss = sr, ss
+	v.summed = false
+
+	var ranks, indices []int
+	err := checkErr(
+		dec.Decode(v.bits),
+		dec.Decode(&ranks),
+		dec.Decode(&indices),
+		dec.Decode(&v.popcount),
+		dec.Decode(&v.length),
+		dec.Decode(&v.initialized),
+	)
+	v.ranks, v.indices = samplesOf(ranks), samplesOf(indices)
 
 	return err
 }
@@ -275,81 +1782,218 @@ func (v *Vector) GobDecode(data []byte) error {
 // on Bitmaps" by Navarro et al., with some minor
 // modifications.
 func (v *Vector) select11(i int) int {
-	const m = 0xC000000000000000
+	idx, _ := v.scan11(v.rankBlock(i), i)
+	return idx
+}
 
-	j := (i - 1) / ss
-	q := v.indices[j] / sr
+// rank11 returns the number of values that begin
+// before the kth bit. k must not be greater than the
+// beginning of the terminating bits.
+func (v *Vector) rank11(k int) int {
+	if !v.indexBuilt() {
+		v.buildIndex()
+	}
 
-	k := 0
-	r := 0
-	rq := v.ranks[q:]
-	for k, r = range rq {
-		if r >= i {
-			k--
-			break
+	block := k / v.sr
+	if block >= v.ranks.length() {
+		block = v.ranks.length() - 1
+	}
+
+	rank := v.ranks.at(block)
+	vbits := v.bits.Bits()
+	for w := (block * v.sr) >> 6; w<<6 < k && w < len(vbits); w++ {
+		next := uint64(0)
+		if w+1 < len(vbits) {
+			next = vbits[w+1]
+		}
+
+		s := starts11_64(vbits[w], next)
+		if n := k - w<<6; n < 64 {
+			s &= 1<<uint(n) - 1
 		}
+		rank += bit.PopCount(s)
+	}
+
+	return rank
+}
+
+// rankBlock returns the rank sampling
+// block that contains the ith 11 pair.
+func (v *Vector) rankBlock(i int) int {
+	if !v.indexBuilt() {
+		v.buildIndex()
 	}
 
+	j := (i - 1) / v.ss
+	q := v.indices.at(j) / v.sr
+
+	// The ranks are non-decreasing so the
+	// block is the one before the first
+	// sample that is not less than i.
+	k := sort.Search(v.ranks.length()-q, func(k int) bool {
+		return v.ranks.at(q+k) >= i
+	})
+
+	return q + k - 1
+}
+
+// scan11 returns the index of the ith 11 pair
+// starting from the given rank sampling block
+// and the number of words read to find it.
+func (v *Vector) scan11(block, i int) (int, int) {
 	idx := 0
-	rank := rq[k]
+	rank := v.ranks.at(block)
 	vbits := v.bits.Bits()
-	aidx := ((q + k) * sr) >> 6
-
-	vbits = vbits[aidx:]
-	for ii, b := range vbits {
-		rank += popcount11_64(b)
-
-		// If b ends with 11 and the next bits
-		// starts with 1, then the 11 in b is
-		// not the beginning of an encoded value,
-		// but popcount11_64 has already counted
-		// it so we need to subtract 1 to rank
-		if b&m == m && vbits[ii+1]&1 == 1 {
-			rank--
+	aidx := (block * v.sr) >> 6
+
+	// Missing words are treated as zeros
+	// so there are no 11s beyond them
+	ii := 0
+	if aidx < len(vbits) {
+		vbits = vbits[aidx:]
+	} else {
+		vbits = nil
+	}
+	for ii = range vbits {
+		next := uint64(0)
+		if ii+1 < len(vbits) {
+			next = vbits[ii+1]
 		}
 
+		s := starts11_64(vbits[ii], next)
+		popcnt := bit.PopCount(s)
+		rank += popcnt
+
 		if rank >= i {
 			idx = (aidx + ii) << 6
 			overflow := rank - i
-			popcnt := popcount11_64(b)
-			if b&m == m && vbits[ii+1]&1 == 1 {
-				popcnt--
-			}
-
-			idx += select11_64(b, popcnt-overflow)
+			idx += bit.Select(s, popcnt-overflow)
 
 			break
 		}
 	}
 
-	return idx
+	return idx, ii + 1
 }
 
-// popcount11 counts the number of 11 pairs
-// in v. This assumes that v doesn't contain
-// more than 3 consecutive 1s. This assumption
-// is satisfied since the minimum encoded value
-// is 011.
-func popcount11_64(v uint64) int {
-	// Reduce cluster of 1s by 1.
-	// This makes 11 to 01, 111 to 011,
-	// and unsets all 1s.
-	v &= v >> 1
+// MaxScanLength returns the maximum number of words that Get
+// goes through to find a value. Get finds the rank sampling block
+// that contains the value using the samples and then scans the
+// words of the block up to the one where the value starts, so
+// this is at most the number of words in a rank sampling block.
+// This uses the rank samples to skip the blocks without values
+// and only reads the last few words of the other blocks.
+func (v *Vector) MaxScanLength() int {
+	if v.length == 0 {
+		return 0
+	} else if !v.indexBuilt() {
+		v.buildIndex()
+	}
+
+	words := v.bits.Bits()
+	nbits := v.bits.Len() - 3
+	bwords := v.sr >> 6
+
+	maxlen := 0
+	nblocks := v.ranks.length()
+	for b := 0; b < nblocks; b++ {
+		next := v.length
+		if b+1 < nblocks {
+			next = v.ranks.at(b + 1)
+		}
+		if next == v.ranks.at(b) {
+			continue
+		}
+
+		// The longest scan in a block is the
+		// one for its last value. Since codes
+		// are short, this is at most a few
+		// words from the end of the block.
+		first := b * bwords
+		last := first + bwords - 1
+		if n := (nbits - 1) >> 6; last > n {
+			last = n
+		}
+		if last >= len(words) {
+			last = len(words) - 1
+		}
+
+		for w := last; w >= first; w-- {
+			next := uint64(0)
+			if w+1 < len(words) {
+				next = words[w+1]
+			}
+
+			// Exclude the terminating bits
+			s := starts11_64(words[w], next)
+			if n := nbits - w<<6; n < 64 {
+				s &= 1<<uint(n) - 1
+			}
+
+			if s != 0 {
+				if w-first+1 > maxlen {
+					maxlen = w - first + 1
+				}
+				break
+			}
+		}
+	}
+
+	return maxlen
+}
 
-	// Reduces all 11s to 10s
-	// while maintaining all lone 1s.
-	v &= ^(v >> 1)
+// traceSelect returns the rank sampling block where
+// the search for the ith value starts and the number
+// of words scanned to find it. This is used to debug
+// slow Gets.
+func (v *Vector) traceSelect(i int) (sampleBlock int, wordsScanned int) {
+	sampleBlock = v.rankBlock(i + 1)
+	_, wordsScanned = v.scan11(sampleBlock, i+1)
+	return
+}
 
-	// Proceed to regular bit counting
-	return bit.PopCount(v)
+// indexBuilt returns true if the rank
+// and select samples are available.
+func (v *Vector) indexBuilt() bool {
+	return !v.ranks.isNil()
 }
 
-// select11 returns the index of the ith 11 pair.
-func select11_64(v uint64, i int) int {
-	// Same with popcount11
-	v &= v >> 1
-	v &= ^(v >> 1)
+// buildIndex rebuilds the rank and select
+// samples from the bit array.
+func (v *Vector) buildIndex() {
+	words := v.bits.Bits()
+
+	// Exclude the terminating bits
+	nbits := v.bits.Len() - 3
+
+	v.ranks = makeSamples(1, (nbits+v.sr-1)/v.sr+1)
+	v.indices = makeSamples(1, (v.length+v.ss-1)/v.ss+1)
+
+	count := 0
+	for idx := nextStart(words, -1); idx >= 0 && idx < nbits; idx = nextStart(words, idx) {
+		for v.ranks.length()*v.sr <= idx {
+			v.ranks.add(count)
+		}
+
+		if count > 0 && count%v.ss == 0 {
+			v.indices.add(idx ^ 0x3F)
+		}
+		count++
+	}
+
+	for v.ranks.length()*v.sr < nbits {
+		v.ranks.add(count)
+	}
+}
 
-	// Perform regular select
-	return bit.Select(v, i)
+// starts11_64 returns the bits of v that mark
+// the beginning of an encoded value, ie., the
+// first bit of every 11 pair that is followed
+// by a 0. Since a code never contains 110 except
+// at its beginning, this also skips padding bits
+// and 11s that are preceded by a 1. next is the
+// word that comes after v so that 11 pairs that
+// are separated by array boundaries are detected.
+func starts11_64(v, next uint64) uint64 {
+	return v & (v>>1 | next<<63) & ^(v>>2 | next<<62)
 }
@@ -1,11 +1,17 @@
 package fibvec
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
+	"math/bits"
 	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
 	"testing"
-	"unsafe"
 
+	"github.com/robskie/bit"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,57 +32,1778 @@ func TestAddGet(t *testing.T) {
 	}
 }
 
+func TestLazyInit(t *testing.T) {
+	vec := &Vector{}
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+		vec.Add(values[i])
+
+		// Initializing again does nothing
+		vec.init()
+	}
+	assert.Equal(t, values, vec.ToSlice())
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+
+	expected, _ := NewVectorFromSlice(values).GobEncode()
+	data, _ := vec.GobEncode()
+	assert.Equal(t, expected, data)
+
+	// A length without a bit array is discarded
+	vec = &Vector{length: 3, popcount: 3}
+	vec.Add(1)
+	vec.Add(2)
+	assert.Equal(t, []int{1, 2}, vec.ToSlice())
+
+	vec = &Vector{}
+	assert.Equal(t, 0, vec.Len())
+	assert.Equal(t, []int{}, vec.ToSlice())
+	vec.Add(5)
+	assert.Equal(t, 5, vec.Get(0))
+}
+
 func TestAddGetNegative(t *testing.T) {
 	vec := NewVector()
-	values := []int{MinValue, -3, -2, -1, 0, 1, 2, 3, MaxValue}
-	for _, v := range values {
-		vec.Add(v)
+	values := []int{MinValue, -3, -2, -1, 0, 1, 2, 3, MaxValue}
+	for _, v := range values {
+		vec.Add(v)
+	}
+
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+}
+
+func TestAddGetUint64(t *testing.T) {
+	vec := NewVector()
+	values := []uint64{0, 1, 2, 1 << 40, 1<<63 - 4}
+	for _, v := range values {
+		vec.AddUint64(v)
+	}
+
+	for i, v := range values {
+		assert.Equal(t, v, vec.GetUint64(i))
+	}
+	assert.Equal(t, MaxValue, vec.Get(len(values)-1))
+
+	// Values that don't fit in
+	// an int are not wrapped
+	assert.Panics(t, func() { vec.AddUint64(MaxValue + 1) })
+	assert.Panics(t, func() { vec.AddUint64(1<<64 - 4) })
+	assert.Equal(t, len(values), vec.Len())
+
+	vec.Add(-1)
+	assert.Panics(t, func() { vec.GetUint64(len(values)) })
+}
+
+func TestGetGetValuesAgree(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(MaxValue) - rand.Intn(MaxValue))
+	}
+
+	for i := 0; i < vec.Len(); i++ {
+		if !assert.Equal(t, vec.Get(i), vec.GetValues(i, i+1)[0]) {
+			break
+		}
+	}
+}
+
+// TestGetNoMutation checks that Get and GetValues
+// don't modify the bit array. Run with -race to
+// also detect temporary modifications.
+func TestGetNoMutation(t *testing.T) {
+	vec := buildDeterministic(1e4, 1)
+
+	words := append([]uint64(nil), vec.bits.Bits()...)
+	vec.Get(1234)
+	vec.GetValues(10, 5000)
+	assert.Equal(t, words, vec.bits.Bits())
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < vec.Len(); i += 4 {
+				vec.Get(i)
+			}
+			vec.GetValues(g, vec.Len())
+		}(g)
+	}
+	wg.Wait()
+
+	assert.Equal(t, words, vec.bits.Bits())
+}
+
+// TestConcurrentReads checks the values returned by
+// concurrent reads on shared vectors. Run with -race.
+func TestConcurrentReads(t *testing.T) {
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+	}
+
+	frozen := NewVectorFromSlice(values)
+	frozen.Freeze()
+	vecs := []*Vector{
+		NewVectorFromSlice(values),
+		NewVectorWithOptions(WithBase(-1e6)),
+		frozen,
+	}
+	vecs[1].AddBatch(values)
+
+	for _, vec := range vecs {
+		errs := make(chan int, 8)
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := g; i < len(values); i += 8 {
+					if vec.Get(i) != values[i] {
+						errs <- i
+						return
+					}
+				}
+
+				start := g * 1000
+				got := vec.GetValues(start, start+1000)
+				for j, n := range got {
+					if n != values[start+j] {
+						errs <- start + j
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		close(errs)
+
+		for i := range errs {
+			t.Errorf("wrong value at index %d", i)
+		}
+	}
+}
+
+func TestAddBatch(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithBase(-1e6)}} {
+		batched := NewVectorWithOptions(opts...)
+		vec := NewVectorWithOptions(opts...)
+
+		batched.AddBatch(nil)
+		for i := 0; i < 100; i++ {
+			batch := make([]int, rand.Intn(2e3))
+			for j := range batch {
+				batch[j] = rand.Intn(2e6) - 1e6
+				if rand.Intn(100) == 0 && opts == nil {
+					batch[j] = MinValue
+				}
+				vec.Add(batch[j])
+			}
+			batched.AddBatch(batch)
+		}
+
+		expected, err := vec.GobEncode()
+		assert.Nil(t, err)
+		data, err := batched.GobEncode()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, data)
+		assert.Equal(t, vec.ToSlice(), batched.ToSlice())
+	}
+
+	vec := NewVector()
+	vec.AddBatch([]int{1, 2})
+	assert.Panics(t, func() { vec.AddBatch([]int{3, MaxValue + 1}) })
+	assert.Equal(t, []int{1, 2}, vec.ToSlice())
+
+	vec.Freeze()
+	vec.AddBatch([]int{3, 4})
+	assert.Equal(t, []int{1, 2, 3, 4}, vec.ToSlice())
+}
+
+func TestNewVectorFromSlice(t *testing.T) {
+	assert.Equal(t, 0, NewVectorFromSlice(nil).Len())
+
+	values := make([]int, 1e5)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+	}
+
+	vec := NewVectorFromSlice(values)
+	assert.Equal(t, len(values), vec.Len())
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+
+	expected, _ := buildFromValues(values).GobEncode()
+	data, _ := vec.GobEncode()
+	assert.Equal(t, expected, data)
+}
+
+func TestSet(t *testing.T) {
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e3)
+	}
+	vec := NewVectorFromSlice(values)
+
+	for k := 0; k < 1e3; k++ {
+		// Use values with different code lengths
+		i := rand.Intn(len(values))
+		n := rand.Intn(1e3)
+		switch rand.Intn(4) {
+		case 0:
+			n = MinValue
+		case 1:
+			n = -n
+		}
+
+		if k%100 == 0 {
+			i = len(values) - 1
+		}
+
+		values[i] = n
+		vec.Set(i, n)
+		if !assert.Equal(t, n, vec.Get(i)) {
+			return
+		}
+	}
+
+	assert.Equal(t, len(values), vec.Len())
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			return
+		}
+	}
+
+	// The result must be the same
+	// as adding the values directly
+	expected, _ := NewVectorFromSlice(values).GobEncode()
+	data, _ := vec.GobEncode()
+	assert.Equal(t, expected, data)
+
+	sum, ok := vec.Sum()
+	esum, eok := vec.streamSum()
+	assert.Equal(t, esum, sum)
+	assert.Equal(t, eok, ok)
+
+	vec = NewVectorFromSlice([]int{1})
+	vec.Set(0, 2)
+	assert.Equal(t, []int{2}, vec.ToSlice())
+
+	assert.Panics(t, func() { vec.Set(1, 0) })
+	assert.Panics(t, func() { vec.Set(-1, 0) })
+	assert.Panics(t, func() { vec.Set(0, MaxValue+1) })
+}
+
+func TestNormalize(t *testing.T) {
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e3)
+	}
+	vec := NewVectorFromSlice(values)
+
+	for k := 0; k < 100; k++ {
+		i := rand.Intn(len(values))
+		n := rand.Intn(1e6) - 5e5
+		values[i] = n
+		vec.Set(i, n)
+	}
+	vec.Truncate(len(values) - 100)
+	values = values[:len(values)-100]
+	vec.AppendRange(NewVectorFromSlice(values), 10, 500)
+	values = append(values, values[10:500]...)
+
+	vec.Normalize()
+	expected := NewVectorFromSlice(values)
+	assert.True(t, equalBits(expected.bits, vec.bits))
+	assert.Equal(t, expected.ranks, vec.ranks)
+	assert.Equal(t, expected.indices, vec.indices)
+	assert.Equal(t, expected.ContentHash(), vec.ContentHash())
+
+	edata, _ := expected.GobEncode()
+	data, _ := vec.GobEncode()
+	assert.Equal(t, edata, data)
+
+	// Padding bits that are missing
+	// from the bit array are added
+	vec = NewVectorFromSlice(values)
+	vec.Freeze()
+	vec.compact = false
+	assert.False(t, equalBits(expected.bits, vec.bits))
+	vec.Normalize()
+	assert.True(t, equalBits(expected.bits, vec.bits))
+	assert.Equal(t, expected.ranks, vec.ranks)
+	assert.Equal(t, values, vec.ToSlice())
+
+	// Flags are kept
+	flagged := NewVectorWithOptions(WithFlags(1))
+	flagged.AddWithFlag(5, 1)
+	flagged.AddWithFlag(-5, 0)
+	flagged.Normalize()
+	n, f := flagged.GetWithFlag(0)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, 1, f)
+
+	empty := &Vector{}
+	empty.Normalize()
+	assert.Equal(t, 0, empty.Len())
+}
+
+func TestReset(t *testing.T) {
+	vec := NewVectorWithOptions(WithBase(-10))
+	(&Vector{}).Reset()
+
+	for k := 0; k < 3; k++ {
+		values := make([]int, 1e4/(k+1))
+		for i := range values {
+			values[i] = rand.Intn(1e6) - 10
+			vec.Add(values[i])
+		}
+
+		if !assert.Equal(t, values, vec.ToSlice()) {
+			break
+		}
+		for i, v := range values {
+			if !assert.Equal(t, v, vec.Get(i)) {
+				return
+			}
+		}
+
+		ranks := vec.ranks.capacity()
+		vec.Reset()
+		assert.Equal(t, 0, vec.Len())
+		assert.Equal(t, []int{}, vec.ToSlice())
+		assert.Equal(t, ranks, vec.ranks.capacity())
+		assert.Equal(t, -10, vec.base)
+
+		sum, ok := vec.Sum()
+		assert.Equal(t, 0, sum)
+		assert.True(t, ok)
+	}
+
+	// Same as a new vector
+	expected, _ := NewVectorWithOptions(WithBase(-10)).GobEncode()
+	data, _ := vec.GobEncode()
+	assert.Equal(t, expected, data)
+
+	vec.Add(5)
+	assert.Equal(t, 5, vec.Get(0))
+}
+
+func TestTruncate(t *testing.T) {
+	values := make([]int, 1000)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+	}
+
+	vec := NewVectorFromSlice(values)
+	vec.Truncate(400)
+	assert.Equal(t, 400, vec.Len())
+	for i, v := range values[:400] {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+
+	sum := 0
+	for _, v := range values[:400] {
+		sum += v
+	}
+	s, _ := vec.Sum()
+	assert.Equal(t, sum, s)
+
+	assert.Panics(t, func() { vec.Truncate(401) })
+	assert.Panics(t, func() { vec.Truncate(-1) })
+	vec.Truncate(400)
+	assert.Equal(t, 400, vec.Len())
+
+	// Truncating then adding the same values
+	// gives the same vector as adding them
+	// directly for every truncation point
+	// including those before padding bits.
+	values = make([]int, 3000)
+	for i := range values {
+		values[i] = rand.Intn(1e9)
+	}
+	expected, _ := NewVectorFromSlice(values).GobEncode()
+	for _, n := range []int{0, 1, 639, 640, 641, 1500, 2999} {
+		vec := NewVectorFromSlice(values)
+		vec.Truncate(n)
+		vec.AddBatch(values[n:])
+
+		data, _ := vec.GobEncode()
+		if !assert.Equal(t, expected, data) {
+			break
+		}
+	}
+
+	vec = NewVectorFromSlice(values)
+	vec.Truncate(0)
+	assert.Equal(t, []int{}, vec.ToSlice())
+}
+
+func TestGrow(t *testing.T) {
+	values := make([]int, 1e4)
+	nbits := 0
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+		nbits += EncodedLen(values[i])
+	}
+
+	for _, vec := range []*Vector{{}, NewVectorFromSlice(values[:100])} {
+		vec.Grow(nbits)
+		ranks, indices := vec.ranks.capacity(), vec.indices.capacity()
+		rank0, index0 := &vec.ranks.narrow[0], &vec.indices.narrow[0]
+
+		for _, n := range values {
+			vec.Add(n)
+		}
+		assert.Equal(t, ranks, vec.ranks.capacity())
+		assert.Equal(t, indices, vec.indices.capacity())
+		assert.True(t, rank0 == &vec.ranks.narrow[0])
+		assert.True(t, index0 == &vec.indices.narrow[0])
+
+		n := vec.Len() - len(values)
+		assert.Equal(t, values, vec.GetValues(n, vec.Len()))
+	}
+
+	// Samples are reserved
+	// by NewVectorFromSlice
+	vec := NewVectorFromSlice(values)
+	estimate := nbits + 3
+	estimate += estimate / 32
+	assert.Equal(t, estimate/sr+1, vec.ranks.capacity())
+	assert.Equal(t, vec.indices.capacity(), vec.indices.length())
+
+	assert.Panics(t, func() { vec.Grow(-1) })
+}
+
+func TestClone(t *testing.T) {
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+	}
+	vec := NewVectorFromSlice(values)
+
+	clone := vec.Clone()
+	assert.Equal(t, values, clone.ToSlice())
+	assert.Equal(t, vec.ContentHash(), clone.ContentHash())
+
+	expected, _ := vec.GobEncode()
+	data, _ := clone.GobEncode()
+	assert.Equal(t, expected, data)
+
+	// Mutating the original
+	// doesn't affect the clone
+	vec.Set(0, MaxValue)
+	vec.Set(5000, MinValue)
+	vec.Add(1)
+	assert.Equal(t, values, clone.ToSlice())
+	assert.Equal(t, values[5000], clone.Get(5000))
+	assert.Equal(t, len(values), clone.Len())
+
+	clone.Add(2)
+	assert.Equal(t, 2, clone.Get(len(values)))
+	assert.Equal(t, 1, vec.Get(len(values)))
+
+	empty := (&Vector{}).Clone()
+	empty.Add(3)
+	assert.Equal(t, []int{3}, empty.ToSlice())
+}
+
+func TestAddRawCode(t *testing.T) {
+	vec := NewVector()
+	values := []int{MinValue, -1, 0, 1, MaxValue}
+	for i := 0; i < 1e4; i++ {
+		values = append(values, rand.Intn(MaxValue)-rand.Intn(MaxValue))
+	}
+
+	for _, v := range values {
+		vec.AddRawCode(fibencode(toSignMagnitude(v)))
+	}
+
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+
+	fc, lfc := fibencode(10)
+	assert.Panics(t, func() { vec.AddRawCode(fc, lfc+64) })
+	assert.Panics(t, func() { vec.AddRawCode(fc, 2) })
+	assert.Panics(t, func() { vec.AddRawCode([]uint64{fc[0] | 0x18}, lfc) })
+	assert.Panics(t, func() { vec.AddRawCode([]uint64{fc[0] | 1<<lfc}, lfc) })
+	assert.Panics(t, func() { vec.AddRawCode([]uint64{0x2}, 3) })
+	assert.Equal(t, len(values), vec.Len())
+
+	// Codes that can't be decoded
+	// by the vector are rejected
+	dvec := NewVector()
+	dvec.AddBatch([]int{5, 5, 7, 9})
+	dvec = dvec.OptimizeByFrequency()
+	dvec.AddRawCode(fibencode(toSignMagnitude(2)))
+	assert.Panics(t, func() { dvec.AddRawCode(fibencode(toSignMagnitude(3))) })
+	assert.Panics(t, func() { dvec.AddRawCode(fibencode(toSignMagnitude(-1))) })
+	assert.Equal(t, []int{5, 5, 7, 9, 9}, dvec.ToSlice())
+
+	bvec := NewVectorWithOptions(WithBase(10))
+	bvec.AddRawCode(fibencode(toSignMagnitude(5)))
+	bvec.AddRawCode(fibencode(toSignMagnitude(MaxValue - 10)))
+	assert.Panics(t, func() { bvec.AddRawCode(fibencode(toSignMagnitude(-1))) })
+	assert.Panics(t, func() { bvec.AddRawCode(fibencode(toSignMagnitude(MaxValue - 9))) })
+	assert.Equal(t, []int{15, MaxValue}, bvec.ToSlice())
+
+	fvec := NewVectorWithOptions(WithFlags(1))
+	fvec.AddWithFlag(5, 0)
+	fvec.AddWithFlag(7, 0)
+	fvec = fvec.OptimizeByFrequency()
+	fvec.AddRawCode(fibencode(toSignMagnitude(1<<1 | 1)))
+	assert.Panics(t, func() { fvec.AddRawCode(fibencode(toSignMagnitude(2 << 1))) })
+	n, flag := fvec.GetWithFlag(2)
+	assert.Equal(t, 7, n)
+	assert.Equal(t, 1, flag)
+}
+
+func TestMaxLengthCode(t *testing.T) {
+	vec := NewVector()
+	vec.Add(MinValue)
+	assert.Equal(t, maxCodeBits, vec.bits.Len()-3)
+	assert.Equal(t, MinValue, vec.Get(0))
+	assert.Equal(t, []int{MinValue}, vec.GetValues(0, 1))
+
+	values := []int{MinValue}
+	for i := 0; i < 100; i++ {
+		values = append(values, MinValue, i, MaxValue, -i)
+		vec.Add(MinValue)
+		vec.Add(i)
+		vec.Add(MaxValue)
+		vec.Add(-i)
+	}
+	assert.Equal(t, values, vec.GetValues(0, vec.Len()))
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+}
+
+// TestRankBoundary adds long codes that start just before
+// a rank sampling block boundary so that the code and its
+// padding bits cross it.
+func TestRankBoundary(t *testing.T) {
+	tested := 0
+	for k := 60; k <= maxCodeBits; k++ {
+		// Smallest value whose code has k bits
+		n := fromSignMagnitude(fib[k-1] - 2)
+		if n < 0 {
+			n = MinValue
+		}
+		if EncodedLen(n) != k {
+			continue
+		}
+
+		for target := sr - 8; target <= sr; target++ {
+			// Fill with 3 and 4-bit codes until the
+			// next value starts at target. Padding can
+			// make this overshoot so skip those.
+			vec := NewVector()
+			values := []int{}
+			for vec.bits.Len()-3 < target {
+				rem := target - (vec.bits.Len() - 3)
+				m := 0
+				if rem == 4 || rem == 8 {
+					m = 1
+				}
+				vec.Add(m)
+				values = append(values, m)
+			}
+			if vec.bits.Len()-3 != target {
+				continue
+			}
+
+			vec.Add(n)
+			vec.Add(5)
+			values = append(values, n, 5)
+			tested++
+
+			ranks := vec.ranks.ints()
+			vec.buildIndex()
+			if !assert.Equal(t, vec.ranks.ints(), ranks, "%d-bit code at %d", k, target) {
+				return
+			}
+
+			for i, v := range values {
+				if !assert.Equal(t, v, vec.Get(i)) {
+					return
+				}
+			}
+		}
+	}
+
+	assert.True(t, tested > 100)
+}
+
+// TestPaddingSweep adds codes of different lengths
+// at every alignment and checks that no leading 11
+// pair is split by a word boundary.
+func TestPaddingSweep(t *testing.T) {
+	lengths := []int{3, 4, 5, 10, 31, 32, 33, 63, 64, 65, 91, 92, 93}
+	for _, k := range lengths {
+		n := fromSignMagnitude(fib[k-1] - 2)
+		if k == maxCodeBits {
+			n = MinValue
+		}
+		if !assert.Equal(t, k, EncodedLen(n)) {
+			return
+		}
+
+		for fill := 0; fill < 3*64; fill++ {
+			// Fill with 3 and 4-bit codes
+			vec := NewVector()
+			values := []int{}
+			for vec.bits.Len()-3 < fill {
+				m := 0
+				if (fill-vec.bits.Len()+3)%3 != 0 {
+					m = 1
+				}
+				vec.Add(m)
+				values = append(values, m)
+			}
+
+			for i := 0; i < 3; i++ {
+				vec.Add(n)
+				values = append(values, n)
+			}
+
+			for i, v := range values {
+				start := vec.BitOffset(i)
+				if !assert.NotEqual(t, 63, start&63) || !assert.Equal(t, v, vec.Get(i)) {
+					return
+				}
+			}
+			if !assert.Equal(t, values, vec.ToSlice()) {
+				return
+			}
+		}
+	}
+}
+
+func TestGetValues(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e3)
+	for i := range values {
+		v := rand.Intn(MaxValue)
+
+		values[i] = v
+		vec.Add(v)
+	}
+
+	for i := range values {
+		if !assert.Equal(t, values[0:i+1], vec.GetValues(0, i+1)) {
+			break
+		}
+	}
+
+}
+
+// TestGetValuesEndAlloc checks that reading a few
+// values near the end of a large vector doesn't copy
+// the bit array.
+func TestGetValuesEndAlloc(t *testing.T) {
+	if !bytesAliased {
+		t.Skip("the bytes of the bit array are copied")
+	}
+
+	vec := buildDeterministic(1e5, 1)
+	start := vec.Len() - 10
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 10; i++ {
+		vec.GetValues(start, vec.Len())
+	}
+	runtime.ReadMemStats(&after)
+
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < 1<<12)
+}
+
+func TestCollectInto(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(100) - 50)
+	}
+
+	for _, r := range [][2]int{{0, 1}, {0, vec.Len()}, {123, 4567}, {vec.Len() - 1, vec.Len()}} {
+		hist := map[int]int{}
+		vec.CollectInto(r[0], r[1], func(n int) {
+			hist[n]++
+		})
+
+		expected := map[int]int{}
+		for _, n := range vec.GetValues(r[0], r[1]) {
+			expected[n]++
+		}
+		assert.Equal(t, expected, hist)
+	}
+
+	assert.Panics(t, func() { vec.CollectInto(1, 1, func(int) {}) })
+	assert.Panics(t, func() { vec.CollectInto(-1, 1, func(int) {}) })
+	assert.Panics(t, func() { vec.CollectInto(0, vec.Len()+1, func(int) {}) })
+}
+
+// TestDecodeGC checks that the byte view of the bit
+// array keeps it alive even if the vector itself is
+// already unreachable.
+func TestDecodeGC(t *testing.T) {
+	expected := buildDeterministic(1e4, 1).ToSlice()
+
+	d := buildDeterministic(1e4, 1).decoder(0)
+	runtime.GC()
+
+	// Allocate so that freed
+	// memory would be reused
+	garbage := make([][]uint64, 100)
+	for i := range garbage {
+		garbage[i] = make([]uint64, 1e3)
+		for j := range garbage[i] {
+			garbage[i][j] = ^uint64(0)
+		}
+	}
+	runtime.GC()
+
+	for i, v := range expected {
+		n, ok := d.next()
+		if !assert.True(t, ok) || !assert.Equal(t, v, n, "index %d", i) {
+			break
+		}
+	}
+
+	vec := buildDeterministic(1e4, 1)
+	for i := 0; i < 10; i++ {
+		values := vec.GetValues(i*1e3, (i+1)*1e3)
+		runtime.GC()
+		if !assert.Equal(t, expected[i*1e3:(i+1)*1e3], values) {
+			break
+		}
+	}
+}
+
+func TestGetValuesFromOffset(t *testing.T) {
+	vec := NewVectorWithOptions(WithBase(-1e6))
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(2e6) - 1e6)
+	}
+
+	for i := 0; i < 1e3; i++ {
+		start := rand.Intn(vec.Len())
+		count := rand.Intn(vec.Len()-start) + 1
+
+		offset := vec.BitOffset(start)
+		expected := vec.GetValues(start, start+count)
+		if !assert.Equal(t, expected, vec.GetValuesFromOffset(offset, count)) {
+			break
+		}
+	}
+
+	last := vec.BitOffset(vec.Len() - 1)
+	assert.Equal(t, []int{vec.Get(vec.Len() - 1)}, vec.GetValuesFromOffset(last, 1))
+	assert.Panics(t, func() { vec.GetValuesFromOffset(last, 2) })
+	assert.Panics(t, func() { vec.GetValuesFromOffset(last+1, 1) })
+	assert.Panics(t, func() { vec.GetValuesFromOffset(-1, 1) })
+	assert.Panics(t, func() { vec.GetValuesFromOffset(0, 0) })
+	assert.Panics(t, func() { vec.BitOffset(vec.Len()) })
+}
+
+func TestIndicesInBitRange(t *testing.T) {
+	vec := buildDeterministic(1e4, 3)
+	offsets := make([]int, vec.Len())
+	for i := range offsets {
+		offsets[i] = vec.BitOffset(i)
+
+		first, last := vec.IndicesInBitRange(offsets[i], offsets[i]+1)
+		if !assert.Equal(t, [2]int{i, i}, [2]int{first, last}) {
+			break
+		}
+	}
+
+	// Compare random windows
+	// with a linear search
+	vlen := vec.bits.Len()
+	for k := 0; k < 1e3; k++ {
+		start := rand.Intn(vlen)
+		end := start + rand.Intn(4096)
+
+		first := sort.SearchInts(offsets, start)
+		last := sort.SearchInts(offsets, end) - 1
+
+		f, l := vec.IndicesInBitRange(start, end)
+		if !assert.Equal(t, [2]int{first, last}, [2]int{f, l}) {
+			break
+		}
+	}
+
+	first, last := vec.IndicesInBitRange(0, vlen+100)
+	assert.Equal(t, 0, first)
+	assert.Equal(t, vec.Len()-1, last)
+
+	first, last = vec.IndicesInBitRange(offsets[5]+1, offsets[6])
+	assert.Equal(t, first-1, last)
+
+	first, last = NewVector().IndicesInBitRange(0, 100)
+	assert.Equal(t, 0, first)
+	assert.Equal(t, -1, last)
+
+	assert.Panics(t, func() { vec.IndicesInBitRange(-1, 10) })
+	assert.Panics(t, func() { vec.IndicesInBitRange(10, 9) })
+}
+
+func TestGetValuesSafe(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e3)
+	for i := range values {
+		v := rand.Intn(MaxValue)
+
+		values[i] = v
+		vec.Add(v)
+	}
+
+	result, err := vec.GetValuesSafe(0, len(values))
+	assert.Nil(t, err)
+	assert.Equal(t, values, result)
+
+	_, err = vec.GetValuesSafe(0, len(values)+1)
+	assert.NotNil(t, err)
+
+	// Replace the code of the 500th value
+	// after its leading 11 with zeros
+	idx := vec.select11(501) + 2
+	bits := vec.bits.Bits()
+	for i := idx; i < idx+256; i++ {
+		bits[i>>6] &= ^(1 << uint(i&63))
+	}
+
+	result, err = vec.GetValuesSafe(0, len(values))
+	if assert.IsType(t, &DecodeError{}, err) {
+		assert.Equal(t, 500, err.(*DecodeError).Index)
+	}
+	assert.Equal(t, values[:500], result)
+}
+
+func TestTryGet(t *testing.T) {
+	vec := buildDeterministic(1e3, 2)
+	values := vec.ToSlice()
+
+	for i, v := range values {
+		n, err := vec.TryGet(i)
+		if !assert.Nil(t, err) || !assert.Equal(t, v, n) {
+			break
+		}
+	}
+
+	_, err := vec.TryGet(-1)
+	assert.ErrorIs(t, err, ErrInvalidIndex)
+	_, err = vec.TryGet(len(values))
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+	_, err = NewVector().TryGet(0)
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+
+	result, err := vec.TryGetValues(10, 20)
+	assert.Nil(t, err)
+	assert.Equal(t, values[10:20], result)
+	_, err = vec.TryGetValues(-1, 20)
+	assert.ErrorIs(t, err, ErrInvalidIndex)
+	_, err = vec.TryGetValues(0, len(values)+1)
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+	_, err = vec.TryGetValues(5, 5)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	// Invalid codes don't panic
+	idx := vec.select11(501) + 2
+	bits := vec.bits.Bits()
+	for i := idx; i < idx+256; i++ {
+		bits[i>>6] &= ^(1 << uint(i&63))
+	}
+	_, err = vec.TryGet(500)
+	assert.IsType(t, &DecodeError{}, err)
+}
+
+// rankBlockLinear is the linear search
+// version of rankBlock used as reference.
+func rankBlockLinear(v *Vector, i int) int {
+	j := (i - 1) / ss
+	q := v.indices.at(j) / sr
+
+	k := q
+	for ; k < v.ranks.length(); k++ {
+		if v.ranks.at(k) >= i {
+			break
+		}
+	}
+
+	return k - 1
+}
+
+func TestRankBlock(t *testing.T) {
+	for _, limit := range []int{10, 1e6, MaxValue} {
+		vec := NewVector()
+		for i := 0; i < 1e4; i++ {
+			vec.Add(rand.Intn(limit))
+		}
+
+		for i := 1; i < vec.ranks.length(); i++ {
+			assert.True(t, vec.ranks.at(i-1) <= vec.ranks.at(i))
+		}
+
+		offsets := []int{}
+		for i, n := 0, vec.bits.Len()-3; i < n; i++ {
+			if vec.bits.Get(i, 3) == 0x3 {
+				offsets = append(offsets, i)
+			}
+		}
+
+		for i := 1; i <= vec.Len(); i++ {
+			if !assert.Equal(t, rankBlockLinear(vec, i), vec.rankBlock(i)) {
+				break
+			}
+			if !assert.Equal(t, offsets[i-1], vec.select11(i)) {
+				break
+			}
+		}
+	}
+}
+
+func TestTraceSelect(t *testing.T) {
+	// Each zero is encoded as 110 so
+	// that the ith value starts at 3i
+	vec := NewVector()
+	vec.Freeze()
+	for i := 0; i < 1000; i++ {
+		vec.Add(0)
+	}
+
+	block, words := vec.traceSelect(0)
+	assert.Equal(t, 0, block)
+	assert.Equal(t, 1, words)
+
+	// 3*30 = 90 is in the 2nd word
+	block, words = vec.traceSelect(30)
+	assert.Equal(t, 0, block)
+	assert.Equal(t, 2, words)
+
+	// 3*170 = 510 is in the 8th word
+	block, words = vec.traceSelect(170)
+	assert.Equal(t, 0, block)
+	assert.Equal(t, 8, words)
+
+	// 3*200 = 600 is in the 2nd word
+	// of the 2nd rank sampling block
+	block, words = vec.traceSelect(200)
+	assert.Equal(t, 1, block)
+	assert.Equal(t, 2, words)
+}
+
+func TestMaxScanLength(t *testing.T) {
+	assert.Equal(t, 0, (&Vector{}).MaxScanLength())
+	assert.Equal(t, 0, NewVector().MaxScanLength())
+
+	// Clusters of small values make the
+	// select samples close to each other
+	// while large values spread them apart.
+	vec := NewVector()
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 2e3; j++ {
+			vec.Add(rand.Intn(4))
+		}
+		for j := 0; j < 1e3; j++ {
+			vec.Add(rand.Intn(MaxValue))
+		}
+	}
+
+	maxScan := func(vec *Vector) int {
+		m := 0
+		for i := 0; i < vec.Len(); i++ {
+			if _, n := vec.traceSelect(i); n > m {
+				m = n
+			}
+		}
+		return m
+	}
+	assert.Equal(t, maxScan(vec), vec.MaxScanLength())
+	assert.True(t, vec.MaxScanLength() <= sr>>6)
+
+	small := NewVectorWithOptions(WithRankBlock(128))
+	small.AddBatch(vec.ToSlice()[:5e3])
+	assert.Equal(t, maxScan(small), small.MaxScanLength())
+	assert.True(t, small.MaxScanLength() <= 2)
+
+	// A value at the start of the bit
+	// array takes a single word
+	one := NewVector()
+	one.Add(1)
+	assert.Equal(t, 1, one.MaxScanLength())
+	assert.Equal(t, maxScan(one), one.MaxScanLength())
+
+	// Large values in a compact vector
+	// without an index and with blocks
+	// that don't contain any value
+	large := NewVectorWithOptions(WithRankBlock(64), WithoutIndex())
+	for i := 0; i < 1e3; i++ {
+		large.Add(MinValue + rand.Intn(10))
+		large.Add(rand.Intn(4))
+	}
+	large.Freeze()
+	assert.Equal(t, maxScan(large), large.MaxScanLength())
+
+	for k := 0; k < 20; k++ {
+		vec := NewVectorWithOptions(WithRankBlock(256))
+		for i := 0; i < 2e3; i++ {
+			vec.Add(rand.Intn(MaxValue) >> uint(rand.Intn(63)))
+		}
+		if !assert.Equal(t, maxScan(vec), vec.MaxScanLength()) {
+			break
+		}
+	}
+}
+
+func TestWorstCaseIndices(t *testing.T) {
+	// Large values fill every rank sampling block
+	// so the worst case scans a whole block
+	vec := buildDeterministic(1e4, 1)
+	idx := worstCaseIndices(vec)
+	assert.Equal(t, sr>>6, vec.MaxScanLength())
+	assert.True(t, len(idx) > 0)
+	for _, i := range idx {
+		_, n := vec.traceSelect(i)
+		assert.Equal(t, sr>>6, n)
+	}
+}
+
+func TestToSlice(t *testing.T) {
+	assert.Equal(t, []int{}, (&Vector{}).ToSlice())
+
+	vec := NewVector()
+	assert.Equal(t, []int{}, vec.ToSlice())
+
+	vec.Add(MinValue)
+	vec.Add(-1)
+	vec.Add(MaxValue)
+	assert.Equal(t, []int{vec.Get(0), vec.Get(1), vec.Get(2)}, vec.ToSlice())
+	assert.Equal(t, []int{MinValue, -1, MaxValue}, vec.ToSlice())
+
+	vec = NewVector()
+
+	values := make([]int, 1e4)
+	for i := range values {
+		v := rand.Intn(2e6) - 1e6
+
+		values[i] = v
+		vec.Add(v)
+	}
+	assert.Equal(t, values, vec.ToSlice())
+}
+
+func TestToInt64Buffer(t *testing.T) {
+	buf, err := NewVector().ToInt64Buffer()
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{}, buf)
+
+	vec := buildDeterministic(1e4, 1)
+	vec.Add(MinValue)
+	vec.Add(-1)
+
+	buf, err = vec.ToInt64Buffer()
+	assert.Nil(t, err)
+	values := vec.ToSlice()
+	assert.Equal(t, len(values), len(buf))
+	for i, v := range values {
+		if !assert.EqualValues(t, v, buf[i]) {
+			break
+		}
+	}
+
+	// Bypass the range check of Add
+	vec.addCode(fibencode(MaxValue + 1))
+	buf, err = vec.ToInt64Buffer()
+	assert.Len(t, buf, len(values))
+	assert.IsType(t, &DecodeError{}, err)
+	assert.Equal(t, len(values), err.(*DecodeError).Index)
+}
+
+func TestGetMany(t *testing.T) {
+	vec := buildDeterministic(1e5, 1)
+
+	indices := make([]int, 1e3)
+	for i := range indices {
+		indices[i] = rand.Intn(vec.Len())
+	}
+	indices = append(indices, indices[:10]...)
+	indices = append(indices, 0, vec.Len()-1, 500, 501, 499)
+
+	values := vec.GetMany(indices)
+	assert.Len(t, values, len(indices))
+	for k, i := range indices {
+		if !assert.Equal(t, vec.Get(i), values[k]) {
+			break
+		}
+	}
+
+	// Sorted indices are not sorted again
+	sort.Ints(indices)
+	values = vec.GetMany(indices)
+	for k, i := range indices {
+		if !assert.Equal(t, vec.Get(i), values[k]) {
+			break
+		}
+	}
+
+	assert.Equal(t, []int{}, vec.GetMany(nil))
+	assert.Panics(t, func() { vec.GetMany([]int{1, vec.Len()}) })
+	assert.Panics(t, func() { vec.GetMany([]int{-1}) })
+}
+
+func TestGetValuesInto(t *testing.T) {
+	vec := buildDeterministic(1e4, 1)
+	values := vec.ToSlice()
+
+	var buf []int
+	for k := 0; k < 100; k++ {
+		start := rand.Intn(len(values))
+		end := start + 1 + rand.Intn(len(values)-start)
+
+		buf = vec.GetValuesInto(start, end, buf)
+		if !assert.Equal(t, values[start:end], buf) {
+			break
+		}
+	}
+
+	// The buffer is reused if it is large enough
+	buf = make([]int, 3, 20)
+	res := vec.GetValuesInto(5, 15, buf)
+	assert.Equal(t, values[5:15], res)
+	assert.Equal(t, &buf[:1][0], &res[0])
+
+	res = vec.GetValuesInto(0, 30, buf)
+	assert.Equal(t, values[:30], res)
+
+	assert.Panics(t, func() { vec.GetValuesInto(5, 5, buf) })
+	assert.Panics(t, func() { vec.GetValuesInto(-1, 5, buf) })
+	assert.Panics(t, func() { vec.GetValuesInto(0, len(values)+1, buf) })
+}
+
+func TestGetValuesChecked(t *testing.T) {
+	vec := buildDeterministic(1e4, 2)
+	vec.Add(MinValue)
+	vec.Add(MaxValue)
+
+	values := vec.ToSlice()
+	result, err := vec.GetValuesChecked(0, vec.Len())
+	assert.Nil(t, err)
+	assert.Equal(t, values, result)
+
+	result, err = vec.GetValuesChecked(5000, 5100)
+	assert.Nil(t, err)
+	assert.Equal(t, values[5000:5100], result)
+
+	// Bypass the range check of Add
+	vec.addCode(fibencode(MaxValue + 1))
+	vec.Add(1)
+	result, err = vec.GetValuesChecked(9990, vec.Len())
+	assert.Equal(t, values[9990:], result)
+	assert.IsType(t, &DecodeError{}, err)
+	assert.Equal(t, len(values), err.(*DecodeError).Index)
+
+	assert.Panics(t, func() { vec.GetValuesChecked(1, 1) })
+	assert.Panics(t, func() { vec.GetValuesChecked(0, vec.Len()+1) })
+}
+
+func TestEncodeDecode(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e5)
+	for i := range values {
+		v := rand.Intn(MaxValue)
+
+		values[i] = v
+		vec.Add(v)
+	}
+
+	data, _ := vec.GobEncode()
+	nvec := NewVector()
+	nvec.GobDecode(data)
+
+	for i, v := range values {
+		if !assert.Equal(t, v, nvec.Get(i)) {
+			break
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e5)
+	for i := range values {
+		v := int(rand.Uint32())
+
+		values[i] = v
+		vec.Add(v)
+	}
+
+	size := vec.Size()
+	vec.Freeze()
+	assert.True(t, vec.Size() < size)
+
+	for i := 0; i < 1e3; i++ {
+		v := int(rand.Uint32())
+
+		values = append(values, v)
+		vec.Add(v)
+	}
+
+	for i, v := range values {
+		if !assert.Equal(t, v, vec.Get(i)) {
+			break
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	vec := NewVector()
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e6) - 5e5
+	}
+	sort.Ints(values)
+	for _, v := range values {
+		vec.Add(v)
+	}
+
+	thresholds := []int{values[0], values[len(values)/2], 0, 1e6}
+	for _, th := range thresholds {
+		below, atOrAbove := vec.Partition(th)
+		split := sort.SearchInts(values, th)
+		if !assert.Equal(t, split, below.Len()) {
+			break
+		}
+
+		var result []int
+		if below.Len() > 0 {
+			result = append(result, below.GetValues(0, below.Len())...)
+			assert.True(t, result[len(result)-1] < th)
+		}
+		if atOrAbove.Len() > 0 {
+			assert.True(t, atOrAbove.Get(0) >= th)
+			result = append(result, atOrAbove.GetValues(0, atOrAbove.Len())...)
+		}
+		assert.Equal(t, values, result)
+	}
+}
+
+func TestRangeQuery(t *testing.T) {
+	vec := NewVector()
+	assert.Equal(t, []int{}, vec.RangeQuery(0, 10))
+
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e5) - 5e4
+	}
+	sort.Ints(values)
+	for _, v := range values {
+		vec.Add(v)
+	}
+
+	windows := [][2]int{
+		{-1e6, 1e6},
+		{0, 0},
+		{-100, 100},
+		{values[10], values[20]},
+		{values[9999], values[9999]},
+		{1e6, 2e6},
+		{-2e6, -1e6},
+		{10, -10},
+	}
+	for _, w := range windows {
+		expected := []int{}
+		for _, v := range values {
+			if v >= w[0] && v <= w[1] {
+				expected = append(expected, v)
+			}
+		}
+
+		if !assert.Equal(t, expected, vec.RangeQuery(w[0], w[1])) {
+			break
+		}
+	}
+}
+
+func TestSearchSorted(t *testing.T) {
+	vec := NewVector()
+	index, found := vec.SearchSorted(0)
+	assert.Equal(t, 0, index)
+	assert.False(t, found)
+	assert.Equal(t, 0, vec.LowerBound(0))
+	assert.Equal(t, 0, vec.UpperBound(0))
+
+	values := make([]int, 1e4)
+	for i := range values {
+		values[i] = rand.Intn(1e4) - 5e3
+	}
+	sort.Ints(values)
+	for _, v := range values {
+		vec.Add(v)
+	}
+
+	targets := []int{values[0], values[len(values)-1], -1e6, 1e6}
+	for i := 0; i < 1e3; i++ {
+		targets = append(targets, rand.Intn(1.2e4)-6e3)
+	}
+	for _, target := range targets {
+		expected := sort.SearchInts(values, target)
+		index, found := vec.SearchSorted(target)
+		if !assert.Equal(t, expected, index) {
+			break
+		}
+		assert.Equal(t, expected < len(values) && values[expected] == target, found)
+		assert.Equal(t, expected, vec.LowerBound(target))
+
+		upper := sort.Search(len(values), func(i int) bool {
+			return values[i] > target
+		})
+		assert.Equal(t, upper, vec.UpperBound(target))
+	}
+}
+
+func TestExtend(t *testing.T) {
+	a := buildDeterministic(1e4, 1)
+	b := buildDeterministic(1e4, 2)
+
+	values := append(a.ToSlice(), b.ToSlice()...)
+	expected := NewVector()
+	for _, n := range values {
+		expected.Add(n)
+	}
+
+	a.Extend(b)
+	assert.Equal(t, expected.Len(), a.Len())
+	assert.Equal(t, expected.bits.Bits(), a.bits.Bits())
+	assert.Equal(t, expected.ranks, a.ranks)
+	assert.Equal(t, expected.indices, a.indices)
+	assert.Equal(t, values, a.GetValues(0, a.Len()))
+
+	// Extend with itself
+	a.Extend(a)
+	assert.Equal(t, append(values, values...), a.ToSlice())
+
+	// Extend with a different base
+	c := NewVectorWithOptions(WithBase(100))
+	c.Add(100)
+	c.Add(250)
+	d := NewVector()
+	d.Add(-1)
+	d.Extend(c)
+	d.Extend(NewVector())
+	assert.Equal(t, []int{-1, 100, 250}, d.ToSlice())
+}
+
+func TestAppend(t *testing.T) {
+	a := buildDeterministic(5e4, 3)
+	b := buildDeterministic(5e4, 4)
+
+	values := append(a.ToSlice(), b.ToSlice()...)
+	expected := NewVectorFromSlice(values)
+
+	a.Append(b)
+	assert.Equal(t, expected.Len(), a.Len())
+	assert.Equal(t, values, a.ToSlice())
+	assert.Equal(t, expected.ranks, a.ranks)
+	assert.Equal(t, expected.indices, a.indices)
+	for _, i := range []int{0, 5e4 - 1, 5e4, 5e4 + 1, 1e5 - 1} {
+		assert.Equal(t, values[i], a.Get(i))
+	}
+
+	edata, _ := expected.GobEncode()
+	data, _ := a.GobEncode()
+	assert.Equal(t, edata, data)
+}
+
+func TestAppendRange(t *testing.T) {
+	src := buildDeterministic(1e4, 1)
+	values := src.ToSlice()
+
+	vec := NewVector()
+	vec.Add(-1)
+	ranges := [][2]int{{0, 1}, {10, 10}, {123, 4567}, {9000, 1e4}, {0, 1e4}}
+	expected := []int{-1}
+	for _, r := range ranges {
+		vec.AppendRange(src, r[0], r[1])
+		expected = append(expected, values[r[0]:r[1]]...)
+	}
+
+	assert.Equal(t, len(expected), vec.Len())
+	for i, n := range expected {
+		if !assert.Equal(t, n, vec.Get(i)) {
+			break
+		}
+	}
+
+	vec.AppendRange(vec, 1, 3)
+	assert.Equal(t, append(expected, values[0], values[123]), vec.ToSlice())
+
+	assert.Panics(t, func() { vec.AppendRange(src, 2, 1) })
+	assert.Panics(t, func() { vec.AppendRange(src, -1, 1) })
+	assert.Panics(t, func() { vec.AppendRange(src, 0, src.Len()+1) })
+}
+
+func TestContentHash(t *testing.T) {
+	vec := NewVector()
+	other := NewVector()
+	for i := 0; i < 1e4; i++ {
+		v := rand.Intn(1e6)
+		vec.Add(v)
+		other.Add(v)
+	}
+	assert.Equal(t, vec.ContentHash(), other.ContentHash())
+
+	data, _ := vec.GobEncode()
+	nvec := NewVector()
+	nvec.GobDecode(data)
+	assert.Equal(t, vec.ContentHash(), nvec.ContentHash())
+
+	hash := vec.ContentHash()
+	vec.Add(1)
+	other.Add(2)
+	assert.NotEqual(t, hash, vec.ContentHash())
+	assert.NotEqual(t, vec.ContentHash(), other.ContentHash())
+}
+
+// TestDecodeV1 checks that vectors serialized
+// before the format version was introduced can
+// still be decoded.
+func TestDecodeV1(t *testing.T) {
+	values := []int{0, 1, -1, 2, 100, -100, 12345, MaxValue, MinValue, 7}
+	words := []uint64{
+		0x540a40a22201519b,
+		0x0a8c11d911094a24,
+		0x4a5122a052051110,
+		0x222015190850c044,
+		0xca1094a24540a40a,
+		0x4489009122282297,
+		0x00000038e4145045,
+	}
+
+	bits := bit.NewArray(0)
+	for _, w := range words[:len(words)-1] {
+		bits.Add(w, 64)
+	}
+	bits.Add(words[len(words)-1], 39)
+
+	// Version 1 field order
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	assert.Nil(t, checkErr(
+		enc.Encode(bits),
+		enc.Encode([]int{0}),
+		enc.Encode([]int{0}),
+		enc.Encode(len(values)),
+		enc.Encode(len(values)),
+		enc.Encode(true),
+	))
+
+	vec := &Vector{}
+	assert.Nil(t, vec.GobDecode(buf.Bytes()))
+	assert.Equal(t, values, vec.ToSlice())
+	assert.Nil(t, ValidateSerialized(buf.Bytes()))
+
+	sum, ok := vec.Sum()
+	esum, eok := vec.streamSum()
+	assert.Equal(t, esum, sum)
+	assert.Equal(t, eok, ok)
+
+	// Reencoding uses the current version
+	vec.Add(3)
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+
+	nvec := &Vector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.Equal(t, append(values, 3), nvec.ToSlice())
+
+	version := 0
+	assert.Nil(t, gob.NewDecoder(bytes.NewReader(data)).Decode(&version))
+	assert.Equal(t, FormatVersion, version)
+
+	// Unknown versions are rejected
+	buf.Reset()
+	assert.Nil(t, gob.NewEncoder(buf).Encode(FormatVersion+1))
+	assert.NotNil(t, nvec.GobDecode(buf.Bytes()))
+}
+
+func TestSamplingOptions(t *testing.T) {
+	values := make([]int, 1e5)
+	for i := range values {
+		values[i] = rand.Intn(2e6) - 1e6
+	}
+
+	small := NewVectorWithOptions(WithRankBlock(64), WithSelectBlock(32))
+	large := NewVectorWithOptions(WithRankBlock(4096), WithSelectBlock(8192))
+	vec := NewVector()
+	for _, v := range []*Vector{small, large, vec} {
+		v.AddBatch(values)
 	}
 
-	for i, v := range values {
-		if !assert.Equal(t, v, vec.Get(i)) {
-			break
+	for _, v := range []*Vector{small, large} {
+		for i, n := range values {
+			if !assert.Equal(t, n, v.Get(i)) {
+				break
+			}
 		}
+		assert.Equal(t, values[500:1500], v.GetValues(500, 1500))
+
+		// Same bits but different samples
+		assert.True(t, equalBits(vec.bits, v.bits))
+		assert.Equal(t, vec.ContentHash(), v.ContentHash())
+		assert.NotEqual(t, vec.ranks.length(), v.ranks.length())
+
+		data, err := v.GobEncode()
+		assert.Nil(t, err)
+		assert.Nil(t, ValidateSerialized(data))
+		nvec := &Vector{}
+		assert.Nil(t, nvec.GobDecode(data))
+		assert.Equal(t, v.sr, nvec.sr)
+		assert.Equal(t, v.ss, nvec.ss)
+		assert.Equal(t, v.ranks, nvec.ranks)
+		assert.Equal(t, values[777], nvec.Get(777))
+
+		data, err = v.MarshalBinary()
+		assert.Nil(t, err)
+		nvec = &Vector{}
+		assert.Nil(t, nvec.UnmarshalBinary(data))
+		assert.Equal(t, v.indices, nvec.indices)
+		assert.Equal(t, values[777], nvec.Get(777))
+
+		// The settings carry over to new vectors
+		nvec = v.Clone()
+		nvec.Set(3, 5)
+		nvec.Truncate(1e4)
+		nvec.Normalize()
+		assert.Equal(t, v.sr, nvec.sr)
+		assert.Equal(t, values[:3], nvec.GetValues(0, 3))
+		assert.Equal(t, values[4:1e4], nvec.GetValues(4, 1e4))
 	}
+
+	// Smaller blocks take more space
+	assert.True(t, small.Size() > vec.Size())
+	assert.True(t, vec.Size() > large.Size())
+	fmt.Printf("=== SAMPLING SIZES: small %d, default %d, large %d bytes\n",
+		small.Size(), vec.Size(), large.Size())
+
+	// The defaults are not serialized
+	assert.Equal(t, uint64(0), vec.flags()&flagSampling)
+	assert.NotEqual(t, uint64(0), small.flags()&flagSampling)
+
+	assert.Panics(t, func() { WithRankBlock(0) })
+	assert.Panics(t, func() { WithRankBlock(100) })
+	assert.Panics(t, func() { WithSelectBlock(0) })
 }
 
-func TestGetValues(t *testing.T) {
-	vec := NewVector()
-	values := make([]int, 1e3)
+func TestEncodeDecodeWithoutIndex(t *testing.T) {
+	vec := NewVectorWithOptions(WithoutIndex())
+	values := make([]int, 1e5)
 	for i := range values {
-		v := rand.Intn(MaxValue)
+		values[i] = rand.Intn(1e6) - 5e5
+		vec.Add(values[i])
+	}
 
-		values[i] = v
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+
+	indexed, _ := buildFromValues(values).GobEncode()
+	assert.True(t, len(data) < len(indexed))
+
+	nvec := &Vector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.False(t, nvec.indexBuilt())
+	assert.True(t, nvec.ranks.isNil())
+	assert.True(t, nvec.indices.isNil())
+
+	// Validating doesn't build the index
+	assert.Nil(t, ValidateSerialized(data))
+	assert.Nil(t, nvec.ValidateCodes())
+	assert.False(t, nvec.indexBuilt())
+
+	assert.Equal(t, values[123], nvec.Get(123))
+	assert.True(t, nvec.indexBuilt())
+	assert.Equal(t, vec.ranks, nvec.ranks)
+	assert.Equal(t, vec.indices, nvec.indices)
+	for i, v := range values {
+		if !assert.Equal(t, v, nvec.Get(i)) {
+			break
+		}
+	}
+
+	// Adding to a decoded vector
+	// also builds the index
+	nvec = &Vector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	nvec.Add(7)
+	assert.Equal(t, append(values, 7), nvec.ToSlice())
+	assert.Nil(t, ValidateSerialized(data))
+}
+
+func TestDecodeV2(t *testing.T) {
+	vec := NewVectorWithOptions(WithBase(-10))
+	vec.Freeze()
+	values := []int{-10, 0, 10, 12345}
+	for _, v := range values {
 		vec.Add(v)
 	}
 
-	for i := range values {
-		if !assert.Equal(t, values[0:i+1], vec.GetValues(0, i+1)) {
+	// Version 2 field order
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	assert.Nil(t, checkErr(
+		enc.Encode(2),
+		enc.Encode(vec.bits),
+		enc.Encode(vec.ranks.ints()),
+		enc.Encode(vec.indices.ints()),
+		enc.Encode(true),
+		enc.Encode(true),
+		enc.Encode(-10),
+		enc.Encode(false),
+		enc.Encode(vec.popcount),
+		enc.Encode(vec.length),
+		enc.Encode(12345),
+		enc.Encode(true),
+	))
+
+	nvec := &Vector{}
+	assert.Nil(t, nvec.GobDecode(buf.Bytes()))
+	assert.True(t, nvec.compact)
+	assert.Equal(t, -10, nvec.base)
+	assert.Equal(t, values, nvec.ToSlice())
+
+	sum, _ := nvec.Sum()
+	assert.Equal(t, 12345, sum)
+}
+
+func TestDecodeUnknownFlags(t *testing.T) {
+	vec := NewVectorWithOptions(WithImplicitLength(), WithoutIndex())
+	vec.Freeze()
+	vec.Add(1)
+	vec.Add(2)
+
+	data, err := vec.GobEncode()
+	assert.Nil(t, err)
+
+	nvec := &Vector{}
+	assert.Nil(t, nvec.GobDecode(data))
+	assert.True(t, nvec.compact)
+	assert.True(t, nvec.implicitLength)
+	assert.True(t, nvec.implicitIndex)
+	assert.Equal(t, []int{1, 2}, nvec.ToSlice())
+
+	// Simulate a vector that uses a
+	// mode that is not supported yet
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	assert.Nil(t, checkErr(
+		enc.Encode(FormatVersion),
+		enc.Encode(flagCompact|1<<10),
+		enc.Encode(vec.bits),
+	))
+
+	err = nvec.GobDecode(buf.Bytes())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown mode flags 0x400")
+	assert.NotNil(t, ValidateSerialized(buf.Bytes()))
+}
+
+func TestEncodeDecodeEmpty(t *testing.T) {
+	for _, vec := range []*Vector{NewVector(), &Vector{}} {
+		data, err := vec.GobEncode()
+		assert.Nil(t, err)
+
+		nvec := &Vector{}
+		if !assert.Nil(t, nvec.GobDecode(data)) {
+			continue
+		}
+		assert.Equal(t, 0, nvec.Len())
+
+		nvec.Add(7)
+		nvec.Add(-7)
+		assert.Equal(t, 2, nvec.Len())
+		assert.Equal(t, 7, nvec.Get(0))
+		assert.Equal(t, -7, nvec.Get(1))
+		assert.Equal(t, []int{7, -7}, nvec.GetValues(0, 2))
+	}
+}
+
+func TestSerializeRange(t *testing.T) {
+	vec := NewVector()
+	for i := 0; i < 1e4; i++ {
+		vec.Add(rand.Intn(MaxValue) - rand.Intn(MaxValue))
+	}
+
+	ranges := [][2]int{{0, 1}, {0, 1e4}, {1234, 5678}, {9999, 1e4}}
+	for _, r := range ranges {
+		data, err := vec.SerializeRange(r[0], r[1])
+		if !assert.Nil(t, err) {
 			break
 		}
+
+		nvec := NewVector()
+		nvec.GobDecode(data)
+		assert.Nil(t, ValidateSerialized(data))
+		assert.Equal(t, vec.GetValues(r[0], r[1]), nvec.ToSlice())
+
+		// The copied values must be laid out
+		// the same way as when they are added
+		expected := NewVector()
+		for _, v := range vec.GetValues(r[0], r[1]) {
+			expected.Add(v)
+		}
+		edata, _ := expected.GobEncode()
+		assert.Equal(t, edata, data)
 	}
 
+	_, err := vec.SerializeRange(10, 10)
+	assert.NotNil(t, err)
+	_, err = vec.SerializeRange(0, 1e4+1)
+	assert.NotNil(t, err)
 }
 
-func TestEncodeDecode(t *testing.T) {
-	vec := NewVector()
-	values := make([]int, 1e5)
+func TestEncodeDecodeImplicitLength(t *testing.T) {
+	vec := NewVectorWithOptions(WithImplicitLength())
+	data, _ := vec.GobEncode()
+	nvec := NewVector()
+	nvec.GobDecode(data)
+	assert.Equal(t, 0, nvec.Len())
+
+	values := make([]int, 1e4)
 	for i := range values {
-		v := rand.Intn(MaxValue)
+		v := rand.Intn(MaxValue) - rand.Intn(MaxValue)
 
 		values[i] = v
 		vec.Add(v)
 	}
 
-	data, _ := vec.GobEncode()
-	nvec := NewVector()
+	data, _ = vec.GobEncode()
+	nvec = NewVector()
 	nvec.GobDecode(data)
+	assert.Equal(t, vec.Len(), nvec.Len())
+	assert.Equal(t, values, nvec.ToSlice())
 
-	for i, v := range values {
-		if !assert.Equal(t, v, nvec.Get(i)) {
-			break
-		}
-	}
+	nvec.Add(1)
+	assert.Equal(t, 1, nvec.Get(len(values)))
 }
 
 // TestAuxOverhead calculates the
@@ -93,6 +1820,14 @@ func TestAuxOverhead(t *testing.T) {
 	overhead := float64(vec.Size()) - rawsize
 	percentage := (overhead / rawsize) * 100
 
+	// The samples are stored in half the
+	// space they would take as ints
+	samples := vec.ranks.length() + vec.indices.length()
+	assert.False(t, vec.ranks.isWide())
+	assert.False(t, vec.indices.isWide())
+	assert.Equal(t, samples*4, int(overhead))
+	assert.True(t, int(overhead) < samples*bits.UintSize/8)
+
 	fmt.Printf("=== OVERHEAD: %.2f%%\n", percentage)
 }
 
@@ -106,7 +1841,7 @@ func TestCompression(t *testing.T) {
 		vec.Add(v)
 	}
 
-	sizeofUint := int(unsafe.Sizeof(uint(0)))
+	sizeofUint := bits.UintSize / 8
 
 	rawsize := float64(sizeofUint * 1e5)
 	vecsize := float64(vec.Size())
@@ -115,11 +1850,117 @@ func TestCompression(t *testing.T) {
 	fmt.Printf("=== COMPRESSION: %.2f%%\n", percentage)
 }
 
+func TestFixedWidthSize(t *testing.T) {
+	assert.Equal(t, 0, NewVector().FixedWidthSize())
+
+	vec := NewVector()
+	vec.Add(-4)
+	vec.Add(3)
+	assert.Equal(t, 1, vec.FixedWidthSize())
+
+	// Values in a narrow range
+	narrow := NewVector()
+	for i := 0; i < 1e4; i++ {
+		narrow.Add(1000 + rand.Intn(16))
+	}
+	assert.True(t, narrow.FixedWidthSize() < narrow.Size())
+
+	// Mostly small values with a few large ones
+	skewed := NewVector()
+	for i := 0; i < 1e4; i++ {
+		if i%100 == 0 {
+			skewed.Add(rand.Intn(1e9))
+		} else {
+			skewed.Add(rand.Intn(4))
+		}
+	}
+	assert.True(t, skewed.Size() < skewed.FixedWidthSize())
+}
+
+// TestBaseCompression calculates the space
+// saved by setting the base of a vector whose
+// values are all greater than 1e9.
+func TestBaseCompression(t *testing.T) {
+	const base = 1e9
+
+	vec := NewVector()
+	bvec := NewVectorWithOptions(WithBase(base))
+	values := make([]int, 1e5)
+	for i := range values {
+		v := base + rand.Intn(1e3)
+
+		values[i] = v
+		vec.Add(v)
+		bvec.Add(v)
+	}
+
+	data, _ := bvec.GobEncode()
+	nvec := NewVector()
+	nvec.GobDecode(data)
+
+	for i, v := range values {
+		if !assert.Equal(t, v, nvec.Get(i)) {
+			break
+		}
+	}
+	assert.Equal(t, values, bvec.GetValues(0, len(values)))
+	assert.Panics(t, func() { bvec.Add(base - 1) })
+
+	size := float64(vec.Size())
+	bsize := float64(bvec.Size())
+	assert.True(t, bsize < size)
+
+	percentage := ((size - bsize) / size) * 100
+	fmt.Printf("=== BASE COMPRESSION: %.2f%%\n", percentage)
+}
+
+// buildDeterministic creates a vector containing
+// n random values generated from seed so that the
+// benchmark results are comparable between runs.
+func buildDeterministic(n int, seed int64) *Vector {
+	r := rand.New(rand.NewSource(seed))
+
+	vec := NewVector()
+	for i := 0; i < n; i++ {
+		vec.Add(r.Intn(MaxValue))
+	}
+
+	return vec
+}
+
+// worstCaseIndices returns the indices of the
+// values that take MaxScanLength words to find.
+func worstCaseIndices(vec *Vector) []int {
+	maxlen := vec.MaxScanLength()
+
+	idx := []int{}
+	for i := 0; i < vec.Len(); i++ {
+		if _, n := vec.traceSelect(i); n == maxlen {
+			idx = append(idx, i)
+		}
+	}
+
+	return idx
+}
+
+// buildFromValues creates a vector
+// by adding the values one by one.
+func buildFromValues(values []int) *Vector {
+	vec := NewVector()
+	for _, v := range values {
+		vec.Add(v)
+	}
+
+	return vec
+}
+
 func BenchmarkAdd(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+
 	vec := NewVector()
 	values := make([]int, b.N)
 	for i := range values {
-		values[i] = rand.Intn(MaxValue)
+		values[i] = r.Intn(MaxValue)
 	}
 
 	b.ResetTimer()
@@ -128,15 +1969,108 @@ func BenchmarkAdd(b *testing.B) {
 	}
 }
 
-func BenchmarkGet(b *testing.B) {
+func BenchmarkExtend(b *testing.B) {
+	other := buildDeterministic(1e4, 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec := NewVector()
+		vec.Extend(other)
+	}
+}
+
+// BenchmarkExtendAdd appends the values
+// of a vector by adding them one by one.
+func BenchmarkExtendAdd(b *testing.B) {
+	other := buildDeterministic(1e4, 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec := NewVector()
+		for _, n := range other.ToSlice() {
+			vec.Add(n)
+		}
+	}
+}
+
+func BenchmarkAppendRange(b *testing.B) {
+	src := buildDeterministic(1e4, 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec := NewVector()
+		vec.AppendRange(src, 1000, 9000)
+	}
+}
+
+// BenchmarkAppendRangeReencode appends a range
+// of values by decoding and adding them.
+func BenchmarkAppendRangeReencode(b *testing.B) {
+	src := buildDeterministic(1e4, 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec := NewVector()
+		for _, n := range src.GetValues(1000, 9000) {
+			vec.Add(n)
+		}
+	}
+}
+
+func BenchmarkAddBatch(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+
+	values := make([]int, b.N)
+	for i := range values {
+		values[i] = r.Intn(MaxValue)
+	}
+
+	b.ResetTimer()
 	vec := NewVector()
-	for i := 0; i < 1e5; i++ {
-		vec.Add(rand.Intn(MaxValue))
+	vec.AddBatch(values)
+}
+
+// benchmarkBuild adds 1e5 values one by one
+// to a new vector, reserving their bits first
+// if grow is true.
+func benchmarkBuild(b *testing.B, grow bool) {
+	r := rand.New(rand.NewSource(1))
+
+	values := make([]int, 1e5)
+	nbits := 0
+	for i := range values {
+		values[i] = r.Intn(1e6)
+		nbits += EncodedLen(values[i])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec := NewVector()
+		if grow {
+			vec.Grow(nbits)
+		}
+		for _, n := range values {
+			vec.Add(n)
+		}
 	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	benchmarkBuild(b, false)
+}
+
+func BenchmarkBuildGrow(b *testing.B) {
+	benchmarkBuild(b, true)
+}
+
+func BenchmarkGet(b *testing.B) {
+	r := rand.New(rand.NewSource(2))
+	vec := buildDeterministic(1e5, 1)
 
 	idx := make([]int, b.N)
 	for i := range idx {
-		idx[i] = rand.Intn(vec.length)
+		idx[i] = r.Intn(vec.length)
 	}
 
 	b.ResetTimer()
@@ -144,3 +2078,155 @@ func BenchmarkGet(b *testing.B) {
 		vec.Get(idx[i])
 	}
 }
+
+// benchmarkGetMany gets the values at count random indices
+// using either GetMany or Get. If run is greater than 1, the
+// indices come in runs of consecutive indices.
+func benchmarkGetMany(b *testing.B, values []int, count, run int, each bool) {
+	r := rand.New(rand.NewSource(2))
+	vec := NewVectorFromSlice(values)
+
+	idx := make([]int, 0, count)
+	for len(idx) < count {
+		start := r.Intn(vec.length - run)
+		for i := start; i < start+run; i++ {
+			idx = append(idx, i)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !each {
+			vec.GetMany(idx)
+			continue
+		}
+		for _, j := range idx {
+			vec.Get(j)
+		}
+	}
+}
+
+// smallValues returns n values that
+// are less than 1000 in magnitude.
+func smallValues(n int) []int {
+	r := rand.New(rand.NewSource(1))
+
+	values := make([]int, n)
+	for i := range values {
+		values[i] = r.Intn(2e3) - 1e3
+	}
+	return values
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	benchmarkGetMany(b, buildDeterministic(1e5, 1).ToSlice(), 1e4, 1, false)
+}
+
+func BenchmarkGetManyGet(b *testing.B) {
+	benchmarkGetMany(b, buildDeterministic(1e5, 1).ToSlice(), 1e4, 1, true)
+}
+
+func BenchmarkGetManySmall(b *testing.B) {
+	benchmarkGetMany(b, smallValues(1e5), 1e4, 1, false)
+}
+
+func BenchmarkGetManySmallGet(b *testing.B) {
+	benchmarkGetMany(b, smallValues(1e5), 1e4, 1, true)
+}
+
+func BenchmarkGetManyRuns(b *testing.B) {
+	benchmarkGetMany(b, smallValues(1e5), 1e4, 16, false)
+}
+
+func BenchmarkGetManyRunsGet(b *testing.B) {
+	benchmarkGetMany(b, smallValues(1e5), 1e4, 16, true)
+}
+
+// rankBenchVector returns a vector of large values where
+// each select sample spans many rank sampling blocks.
+func rankBenchVector() *Vector {
+	r := rand.New(rand.NewSource(1))
+	vec := NewVector()
+	for i := 0; i < 1e5; i++ {
+		vec.Add(r.Intn(MaxValue))
+	}
+	return vec
+}
+
+func BenchmarkRankBlock(b *testing.B) {
+	vec := rankBenchVector()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec.rankBlock(i%vec.Len() + 1)
+	}
+}
+
+func BenchmarkRankBlockLinear(b *testing.B) {
+	vec := rankBenchVector()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rankBlockLinear(vec, i%vec.Len()+1)
+	}
+}
+
+// BenchmarkGetValuesEnd gets the last few
+// values of a large vector. This should not
+// depend on the size of the vector.
+func BenchmarkGetValuesEnd(b *testing.B) {
+	vec := buildDeterministic(1e6, 1)
+	start := vec.Len() - 10
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec.GetValues(start, vec.Len())
+	}
+}
+
+// BenchmarkGetValuesMid gets a small window deep
+// into the vector. GetValues seeks to the window
+// using select11 so this costs about the same as
+// getting the first few values.
+func BenchmarkGetValuesMid(b *testing.B) {
+	vec := buildDeterministic(1e5, 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec.GetValues(90000, 90010)
+	}
+}
+
+func BenchmarkGetValuesInto(b *testing.B) {
+	vec := buildDeterministic(1e5, 1)
+	buf := make([]int, 10)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = vec.GetValuesInto(90000, 90010, buf)
+	}
+}
+
+func BenchmarkGetValuesStart(b *testing.B) {
+	vec := buildDeterministic(1e5, 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec.GetValues(0, 10)
+	}
+}
+
+// BenchmarkGetWorstCase gets the values that take the
+// longest scans from the start of their rank sampling
+// blocks, ie., the ones returned by worstCaseIndices.
+func BenchmarkGetWorstCase(b *testing.B) {
+	vec := buildDeterministic(1e5, 1)
+	idx := worstCaseIndices(vec)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vec.Get(idx[i%len(idx)])
+	}
+}